@@ -6,8 +6,10 @@ package main
 import (
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -15,8 +17,10 @@ import (
 	"go.uber.org/zap"
 
 	"storj.io/common/fpath"
+	"storj.io/common/memory"
 	"storj.io/linksharing"
 	"storj.io/linksharing/httpserver"
+	"storj.io/linksharing/objectcache"
 	"storj.io/linksharing/sharing"
 	"storj.io/private/cfgstruct"
 	"storj.io/private/process"
@@ -42,7 +46,19 @@ type LinkSharing struct {
 	UseQosAndCC           bool          `user:"true" help:"use congestion control and QOS settings" default:"true"`
 	ClientTrustedIPSList  []string      `user:"true" help:"list of clients IPs (comma separated) which are trusted; usually used when the service run behinds gateways, load balancers, etc."`
 	UseClientIPHeaders    bool          `user:"true" help:"use the headers sent by the client to identify its IP. When true the list of IPs set by --client-trusted-ips-list, when not empty, is used" default:"true"`
+	ListPageSize          int           `user:"true" help:"number of entries in a page of a prefix listing" default:"500"`
+	ListMaxScan           int           `user:"true" help:"maximum number of entries scanned for a page of a prefix listing that is filtered or sorted by size or date" default:"10000"`
+	ReadmeNames           []string      `user:"true" help:"names of the markdown objects (comma separated) rendered beneath prefix listings; README.md, readme.md and index.md when empty"`
 	ConnectionPool        ConnectionPoolConfig
+	Compression           CompressionConfig
+	Archive               ArchiveConfig
+	Preview               PreviewConfig
+	Images                ImageConfig
+	Download              DownloadConfig
+	Cache                 CacheConfig
+	ProjectPool           ProjectPoolConfig
+	AccessCache           AccessCacheConfig
+	AuthService           AuthServiceConfig
 }
 
 // ConnectionPoolConfig is a config struct for configuring RPC connection pool options.
@@ -52,6 +68,79 @@ type ConnectionPoolConfig struct {
 	IdleExpiration time.Duration `user:"true" help:"RPC connection pool idle expiration" default:"2m0s"`
 }
 
+// CompressionConfig is a config struct for configuring on-the-fly response compression.
+type CompressionConfig struct {
+	Enabled bool        `user:"true" help:"compress text-like responses with gzip or brotli for clients that accept it" default:"false"`
+	MaxSize memory.Size `user:"true" help:"largest object body to compress on the fly" default:"4MiB"`
+}
+
+// ArchiveConfig is a config struct for configuring downloads of prefixes as archives.
+type ArchiveConfig struct {
+	MaxObjects int         `user:"true" help:"maximum number of objects in a prefix archive download (0 disables them)" default:"10000"`
+	MaxSize    memory.Size `user:"true" help:"maximum total size of the objects in a prefix archive download (0 disables them)" default:"10GiB"`
+}
+
+// PreviewConfig is a config struct for configuring previews of text objects.
+type PreviewConfig struct {
+	MaxSize memory.Size `user:"true" help:"largest text object to preview in the object page (0 disables previews)" default:"10MiB"`
+	Length  memory.Size `user:"true" help:"how much of a text object to preview in the object page" default:"64KiB"`
+}
+
+// DownloadConfig is a config struct for configuring object downloads.
+type DownloadConfig struct {
+	Parallelism     int         `user:"true" help:"number of chunks of a large download to fetch in parallel (less than 2 downloads a single stream)" default:"1"`
+	ChunkSize       memory.Size `user:"true" help:"size of the chunks of parallel downloads; should divide the segment size" default:"16MiB"`
+	Retries         int         `user:"true" help:"number of times a download that fails mid-stream is resumed where it stopped" default:"3"`
+	CoalesceMaxSize memory.Size `user:"true" help:"largest object whose concurrent downloads are shared (0 disables it)" default:"1MiB"`
+}
+
+// CacheConfig is a config struct for configuring the cache of small objects.
+type CacheConfig struct {
+	MaxObjectSize memory.Size `user:"true" help:"largest object to cache" default:"1MiB"`
+	MemorySize    memory.Size `user:"true" help:"total size of the objects cached in memory (0 disables the memory cache)" default:"0"`
+	DiskSize      memory.Size `user:"true" help:"total size of the objects cached on disk (0 disables the disk cache)" default:"0"`
+	Dir           string      `user:"true" help:"directory under which objects are cached on disk (empty disables the disk cache)" default:""`
+}
+
+// ProjectPoolConfig is a config struct for configuring the reuse of open projects.
+type ProjectPoolConfig struct {
+	Capacity int           `user:"true" help:"number of projects kept open for reuse by requests with the same access grant (0 disables it)" default:"100"`
+	TTL      time.Duration `user:"true" help:"how long after it's opened a project is reused" default:"5m0s"`
+}
+
+// AccessCacheConfig is a config struct for configuring the cache of parsed and resolved accesses.
+type AccessCacheConfig struct {
+	Capacity    int           `user:"true" help:"number of parsed access grants and resolved access keys to cache (0 disables the cache)" default:"10000"`
+	TTL         time.Duration `user:"true" help:"how long parsed access grants and resolved access keys are cached" default:"5m0s"`
+	NegativeTTL time.Duration `user:"true" help:"how long access keys the auth service doesn't know or refuses are cached (0 doesn't cache them)" default:"30s"`
+}
+
+// AuthServiceConfig is a config struct for configuring the connection to the auth service.
+type AuthServiceConfig struct {
+	Endpoints           []string      `user:"true" help:"base urls (comma separated) of more auth service replicas to fail over to, optionally prefixed by their region and suffixed by the name to verify their certificate for, as in region=url#server-name"`
+	Region              string        `user:"true" help:"region of this service; auth service replicas in it are preferred" default:""`
+	TokenFile           string        `user:"true" help:"file to read the auth service token from instead of --auth-service-token; it's read again when it changes" default:""`
+	CertFile            string        `user:"true" help:"client certificate presented to the auth service for mutual TLS" default:""`
+	KeyFile             string        `user:"true" help:"key of the client certificate presented to the auth service" default:""`
+	CAFile              string        `user:"true" help:"bundle of CA certificates to verify the auth service with instead of the system ones" default:""`
+	ServerName          string        `user:"true" help:"name to verify the auth service certificate for instead of the host of its url, for endpoints that don't pin their own" default:""`
+	Timeout             time.Duration `user:"true" help:"timeout of each request to the auth service" default:"5s"`
+	MaxConnsPerHost     int           `user:"true" help:"maximum number of connections to the auth service (0 for no limit)" default:"100"`
+	MaxIdleConnsPerHost int           `user:"true" help:"number of idle connections to the auth service kept open" default:"10"`
+	BreakerThreshold    int           `user:"true" help:"consecutive failures of an auth service replica after which requests to it fail fast (0 disables the circuit breaker)" default:"5"`
+	BreakerCooldown     time.Duration `user:"true" help:"how long requests to a failing auth service replica fail fast before it's tried again" default:"10s"`
+	RetryBudget         int           `user:"true" help:"number of retries of failed auth service requests that can be saved up (0 for no limit)" default:"100"`
+	RetryRatio          float64       `user:"true" help:"number of retries each auth service request adds to the budget" default:"0.1"`
+}
+
+// ImageConfig is a config struct for configuring resizing of image objects.
+type ImageConfig struct {
+	MaxSourceSize   memory.Size `user:"true" help:"largest image object to resize (0 disables resizing)" default:"20MiB"`
+	MaxSourcePixels int         `user:"true" help:"largest number of pixels of an image to resize (0 for no limit)" default:"50000000"`
+	MaxDimension    int         `user:"true" help:"largest width or height of a resized image (0 for no limit)" default:"4096"`
+	MaxConcurrent   int         `user:"true" help:"number of images resized at the same time (0 for no limit)" default:"8"`
+}
+
 var (
 	rootCmd = &cobra.Command{
 		Use:   "link sharing service",
@@ -117,14 +206,39 @@ func cmdRun(cmd *cobra.Command, args []string) (err error) {
 			LandingRedirectTarget: runCfg.LandingRedirectTarget,
 			TxtRecordTTL:          runCfg.TxtRecordTTL,
 			AuthServiceConfig: sharing.AuthServiceConfig{
-				BaseURL: runCfg.AuthServiceBaseURL,
-				Token:   runCfg.AuthServiceToken,
+				BaseURL:             runCfg.AuthServiceBaseURL,
+				Token:               runCfg.AuthServiceToken,
+				Endpoints:           runCfg.AuthService.Endpoints,
+				Region:              runCfg.AuthService.Region,
+				TokenFile:           runCfg.AuthService.TokenFile,
+				CertFile:            runCfg.AuthService.CertFile,
+				KeyFile:             runCfg.AuthService.KeyFile,
+				CAFile:              runCfg.AuthService.CAFile,
+				ServerName:          runCfg.AuthService.ServerName,
+				Timeout:             runCfg.AuthService.Timeout,
+				MaxConnsPerHost:     runCfg.AuthService.MaxConnsPerHost,
+				MaxIdleConnsPerHost: runCfg.AuthService.MaxIdleConnsPerHost,
+				BreakerThreshold:    runCfg.AuthService.BreakerThreshold,
+				BreakerCooldown:     runCfg.AuthService.BreakerCooldown,
+				RetryBudget:         runCfg.AuthService.RetryBudget,
+				RetryRatio:          runCfg.AuthService.RetryRatio,
 			},
 			DNSServer:            runCfg.DNSServer,
 			ConnectionPool:       sharing.ConnectionPoolConfig(runCfg.ConnectionPool),
+			Compression:          sharing.CompressionConfig(runCfg.Compression),
+			Archive:              sharing.ArchiveConfig(runCfg.Archive),
+			Preview:              sharing.PreviewConfig(runCfg.Preview),
+			Images:               sharing.ImageConfig(runCfg.Images),
+			Download:             sharing.DownloadConfig(runCfg.Download),
+			Cache:                objectcache.Config(runCfg.Cache),
+			ProjectPool:          sharing.ProjectPoolConfig(runCfg.ProjectPool),
+			AccessCache:          sharing.AccessCacheConfig(runCfg.AccessCache),
 			UseQosAndCC:          runCfg.UseQosAndCC,
 			ClientTrustedIPsList: runCfg.ClientTrustedIPSList,
 			UseClientIPHeaders:   runCfg.UseClientIPHeaders,
+			ListPageSize:         runCfg.ListPageSize,
+			ListMaxScan:          runCfg.ListMaxScan,
+			ReadmeNames:          runCfg.ReadmeNames,
 		},
 		GeoLocationDB: runCfg.GeoLocationDB,
 	})
@@ -132,6 +246,24 @@ func cmdRun(cmd *cobra.Command, args []string) (err error) {
 		return err
 	}
 
+	// SIGHUP empties the access cache, e.g. after access keys were revoked.
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-hangups:
+				log.Info("purging access cache")
+				peer.Handler.PurgeAccesses()
+			case <-done:
+				return
+			}
+		}
+	}()
+
 	runError := peer.Run(ctx)
 	closeError := peer.Close()
 
@@ -3,11 +3,15 @@ module storj.io/linksharing
 go 1.13
 
 require (
+	github.com/andybalholm/brotli v1.0.3
 	github.com/btcsuite/btcutil v1.0.3-0.20201208143702-a53e38424cce
 	github.com/calebcase/tmpfile v1.0.2 // indirect
 	github.com/miekg/dns v1.0.14
+	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646
 	github.com/oschwald/maxminddb-golang v1.7.0
 	github.com/pkg/errors v0.9.1 // indirect
+	github.com/russross/blackfriday/v2 v2.1.0
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	github.com/spacemonkeygo/errors v0.0.0-20201030155909-2f5f890dbc62 // indirect
 	github.com/spacemonkeygo/monkit/v3 v3.0.13
 	github.com/spf13/cobra v1.1.3
@@ -0,0 +1,252 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package objectcache implements a cache of the contents of small objects in
+// memory and on disk.
+package objectcache
+
+import (
+	"container/list"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/spacemonkeygo/monkit/v3"
+	"github.com/zeebo/errs"
+
+	"storj.io/common/memory"
+)
+
+var mon = monkit.Package()
+
+// Error is the default error class for objectcache.
+var Error = errs.Class("objectcache error")
+
+// Config configures a Cache.
+type Config struct {
+	// MaxObjectSize is the size of the largest object that is cached.
+	MaxObjectSize memory.Size
+	// MemorySize is the total size of the objects cached in memory.
+	MemorySize memory.Size
+	// DiskSize is the total size of the objects cached on disk. objects
+	// evicted from memory are moved to disk.
+	DiskSize memory.Size
+	// Dir is the directory under which objects are cached on disk. the disk
+	// cache is disabled if it's empty.
+	Dir string
+}
+
+// Enabled reports whether the config caches anything.
+func (config Config) Enabled() bool {
+	return config.MaxObjectSize > 0 && (config.MemorySize > 0 || config.diskEnabled())
+}
+
+func (config Config) diskEnabled() bool {
+	return config.DiskSize > 0 && config.Dir != ""
+}
+
+// Key identifies a version of an object. an object that is overwritten gets
+// a new key, so cached contents never need to be invalidated.
+type Key struct {
+	// Access identifies the access the object is read with, which determines
+	// the satellite, project and encryption keys. names alone are ambiguous
+	// across projects.
+	Access  string
+	Bucket  string
+	Key     string
+	Created int64 // unix nanoseconds
+	Size    int64
+}
+
+// entry is a cached object.
+type entry struct {
+	key  Key
+	size int64
+	data []byte // if cached in memory
+	path string // if cached on disk
+}
+
+// tier is a least recently used list of entries within a budget.
+type tier struct {
+	budget  int64
+	used    int64
+	order   *list.List // of *entry, most recently used first
+	entries map[Key]*list.Element
+}
+
+func newTier(budget memory.Size) *tier {
+	return &tier{
+		budget:  budget.Int64(),
+		order:   list.New(),
+		entries: make(map[Key]*list.Element),
+	}
+}
+
+// get returns the entry of key and marks it as recently used.
+func (t *tier) get(key Key) (*entry, bool) {
+	element, ok := t.entries[key]
+	if !ok {
+		return nil, false
+	}
+	t.order.MoveToFront(element)
+	return element.Value.(*entry), true
+}
+
+// add adds e and returns the least recently used entries that no longer fit.
+func (t *tier) add(e *entry) (evicted []*entry) {
+	t.entries[e.key] = t.order.PushFront(e)
+	t.used += e.size
+	for t.used > t.budget {
+		evicted = append(evicted, t.remove(t.order.Back().Value.(*entry).key))
+	}
+	return evicted
+}
+
+// remove removes the entry of key, if there is one.
+func (t *tier) remove(key Key) *entry {
+	element, ok := t.entries[key]
+	if !ok {
+		return nil
+	}
+	delete(t.entries, key)
+	t.order.Remove(element)
+	e := element.Value.(*entry)
+	t.used -= e.size
+	return e
+}
+
+// Cache is a least recently used cache of object contents. recently used
+// objects are kept in memory, and the ones evicted from memory are kept on
+// disk until they are evicted from there too.
+//
+// architecture: Database
+type Cache struct {
+	config Config
+	dir    string
+
+	mu     sync.Mutex
+	memory *tier
+	disk   *tier
+	files  int64
+}
+
+// New creates a new cache. it creates a directory under config.Dir for the
+// disk cache, which is removed by Close.
+func New(config Config) (_ *Cache, err error) {
+	cache := &Cache{
+		config: config,
+		memory: newTier(config.MemorySize),
+		disk:   newTier(config.DiskSize),
+	}
+	if config.diskEnabled() {
+		if err := os.MkdirAll(config.Dir, 0700); err != nil {
+			return nil, Error.Wrap(err)
+		}
+		cache.dir, err = ioutil.TempDir(config.Dir, "objectcache")
+		if err != nil {
+			return nil, Error.Wrap(err)
+		}
+	}
+	return cache, nil
+}
+
+// Cacheable reports whether objects of the given size are cached.
+func (cache *Cache) Cacheable(size int64) bool {
+	return size <= cache.config.MaxObjectSize.Int64() &&
+		(size <= cache.memory.budget || (cache.dir != "" && size <= cache.disk.budget))
+}
+
+// Get returns the cached contents of the object of key. they must not be
+// modified.
+func (cache *Cache) Get(key Key) (_ []byte, ok bool) {
+	cache.mu.Lock()
+	if e, ok := cache.memory.get(key); ok {
+		cache.mu.Unlock()
+		mon.Event("objectcache_memory_hit")
+		return e.data, true
+	}
+	e, ok := cache.disk.get(key)
+	cache.mu.Unlock()
+	if !ok {
+		mon.Event("objectcache_miss")
+		return nil, false
+	}
+
+	data, err := ioutil.ReadFile(e.path)
+	if err != nil || int64(len(data)) != e.size {
+		// the file was evicted while we were reading it.
+		mon.Event("objectcache_miss")
+		return nil, false
+	}
+	mon.Event("objectcache_disk_hit")
+	return data, true
+}
+
+// Put caches data as the contents of the object of key. data must not be
+// modified afterwards.
+func (cache *Cache) Put(key Key, data []byte) {
+	size := int64(len(data))
+	if !cache.Cacheable(size) {
+		return
+	}
+
+	cache.mu.Lock()
+	if _, ok := cache.memory.entries[key]; ok {
+		cache.mu.Unlock()
+		return
+	}
+	if _, ok := cache.disk.entries[key]; ok {
+		cache.mu.Unlock()
+		return
+	}
+	demoted := []*entry{{key: key, size: size, data: data}}
+	if size <= cache.memory.budget {
+		demoted = cache.memory.add(demoted[0])
+	}
+	cache.mu.Unlock()
+
+	for _, e := range demoted {
+		cache.store(e)
+	}
+}
+
+// store moves the entry evicted from memory to the disk cache, if it fits.
+func (cache *Cache) store(e *entry) {
+	if cache.dir == "" || e.size > cache.disk.budget {
+		return
+	}
+
+	cache.mu.Lock()
+	cache.files++
+	path := filepath.Join(cache.dir, strconv.FormatInt(cache.files, 10))
+	cache.mu.Unlock()
+
+	if err := ioutil.WriteFile(path, e.data, 0600); err != nil {
+		mon.Event("objectcache_store_failed")
+		_ = os.Remove(path)
+		return
+	}
+
+	cache.mu.Lock()
+	if _, ok := cache.disk.entries[e.key]; ok {
+		cache.mu.Unlock()
+		_ = os.Remove(path)
+		return
+	}
+	evicted := cache.disk.add(&entry{key: e.key, size: e.size, path: path})
+	cache.mu.Unlock()
+
+	for _, e := range evicted {
+		_ = os.Remove(e.path)
+	}
+}
+
+// Close removes the disk cache.
+func (cache *Cache) Close() error {
+	if cache.dir == "" {
+		return nil
+	}
+	return Error.Wrap(os.RemoveAll(cache.dir))
+}
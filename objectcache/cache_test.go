@@ -0,0 +1,91 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package objectcache
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/memory"
+	"storj.io/common/testcontext"
+	"storj.io/common/testrand"
+)
+
+func objectKey(key string) Key {
+	return Key{Access: "access", Bucket: "bucket", Key: key, Created: 1, Size: 100}
+}
+
+func TestCacheMemory(t *testing.T) {
+	cache, err := New(Config{MaxObjectSize: 100, MemorySize: 250})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, cache.Close()) }()
+
+	a, b, c := testrand.BytesInt(100), testrand.BytesInt(100), testrand.BytesInt(100)
+	cache.Put(objectKey("a"), a)
+	cache.Put(objectKey("b"), b)
+
+	data, ok := cache.Get(objectKey("a"))
+	require.True(t, ok)
+	assert.Equal(t, a, data)
+
+	// b is the least recently used, so c evicts it.
+	cache.Put(objectKey("c"), c)
+	_, ok = cache.Get(objectKey("b"))
+	assert.False(t, ok)
+	_, ok = cache.Get(objectKey("a"))
+	assert.True(t, ok)
+	_, ok = cache.Get(objectKey("c"))
+	assert.True(t, ok)
+
+	// too large.
+	cache.Put(objectKey("d"), testrand.BytesInt(101))
+	_, ok = cache.Get(objectKey("d"))
+	assert.False(t, ok)
+
+	// other versions of an object are different keys.
+	newer := objectKey("a")
+	newer.Created = 2
+	_, ok = cache.Get(newer)
+	assert.False(t, ok)
+}
+
+func TestCacheDisk(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	cache, err := New(Config{
+		MaxObjectSize: memory.KiB,
+		MemorySize:    150,
+		DiskSize:      250,
+		Dir:           ctx.Dir("cache"),
+	})
+	require.NoError(t, err)
+
+	objects := map[string][]byte{}
+	for _, key := range []string{"a", "b", "c", "d"} {
+		objects[key] = testrand.BytesInt(100)
+		cache.Put(objectKey(key), objects[key])
+	}
+
+	// d is in memory, b and c were moved to disk and a was evicted from it.
+	for key, cached := range map[string]bool{"a": false, "b": true, "c": true, "d": true} {
+		data, ok := cache.Get(objectKey(key))
+		require.Equal(t, cached, ok, key)
+		if cached {
+			assert.Equal(t, objects[key], data, key)
+		}
+	}
+
+	files, err := ioutil.ReadDir(cache.dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	require.NoError(t, cache.Close())
+	_, err = os.Stat(cache.dir)
+	assert.True(t, os.IsNotExist(err))
+}
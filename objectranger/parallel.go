@@ -0,0 +1,157 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package objectranger
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/zeebo/errs"
+)
+
+// downloadFunc opens a stream of length bytes of an object at offset.
+type downloadFunc func(ctx context.Context, offset, length int64) (io.ReadCloser, error)
+
+// chunk is a part of a range downloaded on its own.
+type chunk struct {
+	offset, length int64
+}
+
+// splitChunks splits the range at offset of the given length into chunks
+// whose boundaries are aligned to multiples of chunkSize.
+func splitChunks(offset, length, chunkSize int64) []chunk {
+	var chunks []chunk
+	for end := offset + length; offset < end; {
+		next := (offset/chunkSize + 1) * chunkSize
+		if next > end {
+			next = end
+		}
+		chunks = append(chunks, chunk{offset: offset, length: next - offset})
+		offset = next
+	}
+	return chunks
+}
+
+// chunkResult is a downloaded chunk.
+type chunkResult struct {
+	data []byte
+	err  error
+}
+
+// parallelReader downloads the chunks of a range in parallel and reads them
+// in order. at most parallelism chunks are downloaded or waiting to be read
+// at any time, which bounds its memory use.
+type parallelReader struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	download downloadFunc
+	wg       sync.WaitGroup
+
+	parallelism int
+
+	chunks  []chunk            // chunks that are not started yet
+	pending []chan chunkResult // started chunks, in order
+
+	current []byte
+	err     error
+}
+
+// newParallelReader starts downloading the range at offset of the given
+// length with download.
+func newParallelReader(ctx context.Context, download downloadFunc, offset, length int64, opts Options) *parallelReader {
+	ctx, cancel := context.WithCancel(ctx)
+	reader := &parallelReader{
+		ctx:      ctx,
+		cancel:   cancel,
+		download: download,
+
+		parallelism: opts.Parallelism,
+		chunks:      splitChunks(offset, length, opts.ChunkSize),
+	}
+	reader.startChunks()
+	return reader
+}
+
+// startChunks starts downloading chunks until parallelism of them are in
+// progress or waiting to be read.
+func (reader *parallelReader) startChunks() {
+	for len(reader.chunks) > 0 && len(reader.pending) < reader.parallelism {
+		reader.startNext()
+	}
+}
+
+// startNext starts downloading the next chunk.
+func (reader *parallelReader) startNext() {
+	c := reader.chunks[0]
+	reader.chunks = reader.chunks[1:]
+
+	result := make(chan chunkResult, 1)
+	reader.pending = append(reader.pending, result)
+
+	reader.wg.Add(1)
+	go func() {
+		defer reader.wg.Done()
+		data, err := reader.fetch(c)
+		result <- chunkResult{data: data, err: err}
+	}()
+}
+
+// fetch downloads c into memory.
+func (reader *parallelReader) fetch(c chunk) (_ []byte, err error) {
+	ctx := reader.ctx
+	defer mon.Task()(&ctx)(&err)
+
+	stream, err := reader.download(ctx, c.offset, c.length)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, c.length)
+	_, err = io.ReadFull(stream, data)
+	return data, errs.Combine(err, stream.Close())
+}
+
+// Read reads the range in order, waiting for chunks to be downloaded.
+func (reader *parallelReader) Read(p []byte) (n int, err error) {
+	for len(reader.current) == 0 {
+		if reader.err != nil {
+			return 0, reader.err
+		}
+
+		// the previous chunk is read, so its memory can go to the next one.
+		reader.current = nil
+		reader.startChunks()
+		if len(reader.pending) == 0 {
+			return 0, io.EOF
+		}
+
+		var result chunkResult
+		select {
+		case result = <-reader.pending[0]:
+		case <-reader.ctx.Done():
+			result.err = reader.ctx.Err()
+		}
+		reader.pending = reader.pending[1:]
+		if result.err != nil {
+			reader.err = result.err
+			reader.cancel()
+			continue
+		}
+
+		reader.current = result.data
+	}
+
+	n = copy(p, reader.current)
+	reader.current = reader.current[n:]
+	return n, nil
+}
+
+// Close stops any downloads in progress and waits for them to exit.
+func (reader *parallelReader) Close() error {
+	reader.cancel()
+	reader.wg.Wait()
+	reader.current, reader.pending, reader.chunks = nil, nil, nil
+	return nil
+}
@@ -0,0 +1,75 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package objectranger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/testcontext"
+	"storj.io/common/testrand"
+)
+
+func TestSplitChunks(t *testing.T) {
+	assert.Equal(t, []chunk{{0, 10}, {10, 10}, {20, 5}}, splitChunks(0, 25, 10))
+	assert.Equal(t, []chunk{{7, 3}, {10, 10}, {20, 2}}, splitChunks(7, 15, 10))
+	assert.Equal(t, []chunk{{12, 5}}, splitChunks(12, 5, 10))
+	assert.Empty(t, splitChunks(5, 0, 10))
+}
+
+func TestParallelReader(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	data := testrand.BytesInt(1000)
+
+	var inFlight, maxInFlight int32
+	download := func(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		return ioutil.NopCloser(bytes.NewReader(data[offset : offset+length])), nil
+	}
+
+	reader := newParallelReader(ctx, download, 45, 900, Options{Parallelism: 3, ChunkSize: 64})
+	got, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	assert.Equal(t, data[45:945], got)
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxInFlight), int32(3))
+}
+
+func TestParallelReaderError(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	data := testrand.BytesInt(100)
+	failure := errors.New("node went away")
+
+	download := func(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+		if offset >= 50 {
+			return nil, failure
+		}
+		return ioutil.NopCloser(bytes.NewReader(data[offset : offset+length])), nil
+	}
+
+	reader := newParallelReader(ctx, download, 0, 100, Options{Parallelism: 2, ChunkSize: 25})
+	got, err := ioutil.ReadAll(reader)
+	require.True(t, errors.Is(err, failure))
+	assert.Equal(t, data[:50], got)
+	require.NoError(t, reader.Close())
+}
@@ -5,9 +5,11 @@ package objectranger
 
 import (
 	"context"
+	"errors"
 	"io"
 
 	"github.com/spacemonkeygo/monkit/v3"
+	"github.com/zeebo/errs"
 
 	"storj.io/common/ranger"
 	"storj.io/uplink"
@@ -15,21 +17,49 @@ import (
 
 var (
 	mon = monkit.Package()
+
+	// ErrObjectChanged is returned when a download opens a different object
+	// than the ranger was created for, because it was replaced in between.
+	ErrObjectChanged = errors.New("object changed during download")
 )
 
+// Options configures how an ObjectRanger downloads ranges.
+type Options struct {
+	// Parallelism is the number of chunks of a range that are downloaded at
+	// the same time. ranges are downloaded as a single stream if it's less
+	// than 2.
+	Parallelism int
+	// ChunkSize is the size of the chunks a range is split into for parallel
+	// downloads. chunks are aligned to multiples of it from the start of the
+	// object, so a divisor of the segment size keeps each chunk within a
+	// segment. at most Parallelism chunks are held in memory per range.
+	ChunkSize int64
+	// Retries is the number of times a download that fails mid-stream is
+	// reopened where it stopped before the failure is returned.
+	Retries int
+}
+
 // ObjectRanger holds all the data needed to make object downloadable.
 type ObjectRanger struct {
 	p      *uplink.Project
 	o      *uplink.Object
 	bucket string
+	opts   Options
 }
 
 // New creates a new object ranger.
 func New(p *uplink.Project, o *uplink.Object, bucket string) ranger.Ranger {
+	return NewWithOptions(p, o, bucket, Options{})
+}
+
+// NewWithOptions creates a new object ranger that downloads ranges as
+// configured by opts.
+func NewWithOptions(p *uplink.Project, o *uplink.Object, bucket string, opts Options) ranger.Ranger {
 	return &ObjectRanger{
 		p:      p,
 		o:      o,
 		bucket: bucket,
+		opts:   opts,
 	}
 }
 
@@ -41,5 +71,44 @@ func (ranger *ObjectRanger) Size() int64 {
 // Range returns object read/close interface.
 func (ranger *ObjectRanger) Range(ctx context.Context, offset, length int64) (_ io.ReadCloser, err error) {
 	defer mon.Task()(&ctx)(&err)
-	return ranger.p.DownloadObject(ctx, ranger.bucket, ranger.o.Key, &uplink.DownloadOptions{Offset: offset, Length: length})
+
+	if ranger.opts.Parallelism > 1 && ranger.opts.ChunkSize > 0 && length > ranger.opts.ChunkSize {
+		return newParallelReader(ctx, ranger.download, offset, length, ranger.opts), nil
+	}
+	return ranger.download(ctx, offset, length)
+}
+
+// download opens a single stream of the given range of the object, which is
+// resumed after failures if retries are configured.
+func (ranger *ObjectRanger) download(ctx context.Context, offset, length int64) (_ io.ReadCloser, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	if ranger.opts.Retries > 0 {
+		return newResumingReader(ctx, ranger.open, offset, length, ranger.opts.Retries)
+	}
+	return ranger.open(ctx, offset, length)
+}
+
+// open opens a download of the given range of the object. downloads are
+// opened by key, so each of them is checked to be of the same object, lest
+// parts of different objects are spliced together.
+func (ranger *ObjectRanger) open(ctx context.Context, offset, length int64) (_ io.ReadCloser, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	download, err := ranger.p.DownloadObject(ctx, ranger.bucket, ranger.o.Key, &uplink.DownloadOptions{Offset: offset, Length: length})
+	if err != nil {
+		return nil, err
+	}
+	if !SameObject(ranger.o, download.Info()) {
+		return nil, errs.Combine(ErrObjectChanged, download.Close())
+	}
+	return download, nil
+}
+
+// SameObject reports whether b is the same version of the object as a, as
+// far as downloads, which open objects by key, can tell.
+func SameObject(a, b *uplink.Object) bool {
+	return a.Key == b.Key &&
+		a.System.Created.Equal(b.System.Created) &&
+		a.System.ContentLength == b.System.ContentLength
 }
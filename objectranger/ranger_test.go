@@ -0,0 +1,23 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package objectranger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"storj.io/uplink"
+)
+
+func TestSameObject(t *testing.T) {
+	created := time.Now()
+	object := &uplink.Object{Key: "a", System: uplink.SystemMetadata{Created: created, ContentLength: 10}}
+
+	assert.True(t, SameObject(object, &uplink.Object{Key: "a", System: uplink.SystemMetadata{Created: created.UTC(), ContentLength: 10}}))
+	assert.False(t, SameObject(object, &uplink.Object{Key: "b", System: uplink.SystemMetadata{Created: created, ContentLength: 10}}))
+	assert.False(t, SameObject(object, &uplink.Object{Key: "a", System: uplink.SystemMetadata{Created: created.Add(time.Second), ContentLength: 10}}))
+	assert.False(t, SameObject(object, &uplink.Object{Key: "a", System: uplink.SystemMetadata{Created: created, ContentLength: 11}}))
+}
@@ -0,0 +1,145 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package objectranger
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/zeebo/errs"
+)
+
+const (
+	// minResumeDelay is the delay before the first attempt to resume a
+	// download. it doubles for each further attempt, up to maxResumeDelay.
+	minResumeDelay = 50 * time.Millisecond
+	maxResumeDelay = time.Second
+)
+
+// resumingReader reads a range of an object, reopening the download where it
+// stopped if it fails mid-stream.
+type resumingReader struct {
+	ctx      context.Context
+	download downloadFunc
+	stream   io.ReadCloser
+
+	offset  int64         // of the next byte to deliver
+	length  int64         // left to deliver, negative to read to the end
+	retries int           // left before giving up
+	delay   time.Duration // before the next attempt to resume
+	failed  error         // of the download, if it needs to be resumed
+}
+
+// newResumingReader opens the range at offset of the given length with
+// download, which is reopened at most retries times after failures.
+func newResumingReader(ctx context.Context, download downloadFunc, offset, length int64, retries int) (*resumingReader, error) {
+	stream, err := download(ctx, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	return &resumingReader{
+		ctx:      ctx,
+		download: download,
+		stream:   stream,
+		offset:   offset,
+		length:   length,
+		retries:  retries,
+	}, nil
+}
+
+// Read reads from the download, resuming it after failures.
+func (reader *resumingReader) Read(p []byte) (n int, err error) {
+	if reader.length >= 0 && int64(len(p)) > reader.length {
+		p = p[:reader.length]
+	}
+	for {
+		if reader.length == 0 {
+			return 0, io.EOF
+		}
+		if reader.failed != nil {
+			// resuming won't bring back an object that was replaced.
+			if reader.retries <= 0 || reader.ctx.Err() != nil || errors.Is(reader.failed, ErrObjectChanged) {
+				return 0, reader.failed
+			}
+			reader.retries--
+			if err := reader.wait(); err != nil {
+				return 0, errs.Combine(reader.failed, err)
+			}
+			if err := reader.resume(); err != nil {
+				// reopening may fail transiently too.
+				continue
+			}
+		}
+
+		n, err = reader.stream.Read(p)
+		reader.offset += int64(n)
+		if reader.length > 0 {
+			reader.length -= int64(n)
+		}
+		if errors.Is(err, io.EOF) && reader.length > 0 {
+			// the download ended before delivering everything.
+			err = io.ErrUnexpectedEOF
+		}
+		if err == nil || errors.Is(err, io.EOF) {
+			return n, err
+		}
+
+		// deliver what was read and resume on the next read.
+		reader.failed = err
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// wait waits before the next attempt to resume the download, exponentially
+// longer for each attempt.
+func (reader *resumingReader) wait() error {
+	if reader.delay == 0 {
+		reader.delay = minResumeDelay
+	} else if reader.delay *= 2; reader.delay > maxResumeDelay {
+		reader.delay = maxResumeDelay
+	}
+
+	timer := time.NewTimer(reader.delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-reader.ctx.Done():
+		return reader.ctx.Err()
+	}
+}
+
+// resume reopens the download at the next byte to deliver after it failed.
+func (reader *resumingReader) resume() (err error) {
+	ctx := reader.ctx
+	defer mon.Task()(&ctx)(&err)
+
+	mon.Event("download_resumed")
+	closeErr := reader.stream.Close()
+
+	stream, err := reader.download(ctx, reader.offset, reader.length)
+	if err != nil {
+		reader.stream = failedStream{}
+		reader.failed = errs.Combine(err, closeErr)
+		return reader.failed
+	}
+	reader.stream = stream
+	reader.failed = nil
+	return nil
+}
+
+// Close closes the download.
+func (reader *resumingReader) Close() error {
+	return reader.stream.Close()
+}
+
+// failedStream takes the place of a download that could not be reopened.
+type failedStream struct{}
+
+func (failedStream) Read([]byte) (int, error) { return 0, io.ErrClosedPipe }
+func (failedStream) Close() error             { return nil }
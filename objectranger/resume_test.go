@@ -0,0 +1,104 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package objectranger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/testcontext"
+	"storj.io/common/testrand"
+)
+
+// flakyStream fails after delivering limit bytes.
+type flakyStream struct {
+	data  []byte
+	limit int
+	err   error
+}
+
+func (stream *flakyStream) Read(p []byte) (int, error) {
+	if stream.limit == 0 {
+		return 0, stream.err
+	}
+	if len(p) > stream.limit {
+		p = p[:stream.limit]
+	}
+	n, err := bytes.NewReader(stream.data).Read(p)
+	stream.data, stream.limit = stream.data[n:], stream.limit-n
+	return n, err
+}
+
+func (stream *flakyStream) Close() error { return nil }
+
+func TestResumingReader(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	data := testrand.BytesInt(1000)
+	failure := errors.New("node went away")
+
+	var offsets []int64
+	download := func(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+		offsets = append(offsets, offset)
+		return &flakyStream{data: data[offset : offset+length], limit: 300, err: failure}, nil
+	}
+
+	t.Run("resumes", func(t *testing.T) {
+		offsets = nil
+		reader, err := newResumingReader(ctx, download, 50, 900, 3)
+		require.NoError(t, err)
+		got, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+		assert.Equal(t, data[50:950], got)
+		assert.Equal(t, []int64{50, 350, 650}, offsets)
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		offsets = nil
+		reader, err := newResumingReader(ctx, download, 0, 1000, 2)
+		require.NoError(t, err)
+		got, err := ioutil.ReadAll(reader)
+		require.True(t, errors.Is(err, failure))
+		require.NoError(t, reader.Close())
+		assert.Equal(t, data[:900], got)
+		assert.Equal(t, []int64{0, 300, 600}, offsets)
+	})
+
+	t.Run("short stream", func(t *testing.T) {
+		short := func(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(data[offset : offset+length/2])), nil
+		}
+		reader, err := newResumingReader(ctx, short, 0, 1000, 0)
+		require.NoError(t, err)
+		_, err = ioutil.ReadAll(reader)
+		require.Equal(t, io.ErrUnexpectedEOF, err)
+	})
+
+	t.Run("object changed", func(t *testing.T) {
+		offsets = nil
+		replaced := func(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+			offsets = append(offsets, offset)
+			if len(offsets) > 1 {
+				return nil, ErrObjectChanged
+			}
+			return &flakyStream{data: data[offset : offset+length], limit: 300, err: failure}, nil
+		}
+		reader, err := newResumingReader(ctx, replaced, 0, 1000, 3)
+		require.NoError(t, err)
+		got, err := ioutil.ReadAll(reader)
+		require.True(t, errors.Is(err, ErrObjectChanged))
+		require.NoError(t, reader.Close())
+		assert.Equal(t, data[:300], got)
+		assert.Equal(t, []int64{0, 300}, offsets)
+	})
+}
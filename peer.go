@@ -30,9 +30,10 @@ type Config struct {
 //
 // architecture: Peer
 type Peer struct {
-	Log    *zap.Logger
-	Mapper *objectmap.IPDB
-	Server *httpserver.Server
+	Log     *zap.Logger
+	Mapper  *objectmap.IPDB
+	Handler *sharing.Handler
+	Server  *httpserver.Server
 }
 
 // New is a constructor for Linksharing Peer.
@@ -49,12 +50,12 @@ func New(log *zap.Logger, config Config) (_ *Peer, err error) {
 		peer.Mapper = objectmap.NewIPDB(reader)
 	}
 
-	handle, err := sharing.NewHandler(log, peer.Mapper, config.Handler)
+	peer.Handler, err = sharing.NewHandler(log, peer.Mapper, config.Handler)
 	if err != nil {
 		return nil, errs.New("unable to create handler: %w", err)
 	}
 
-	peer.Server, err = httpserver.New(log, handle, config.Server)
+	peer.Server, err = httpserver.New(log, peer.Handler, config.Server)
 	if err != nil {
 		return nil, errs.New("unable to create httpserver: %w", err)
 	}
@@ -82,6 +83,10 @@ func (peer *Peer) Close() error {
 		errlist.Add(peer.Server.Close())
 	}
 
+	if peer.Handler != nil {
+		errlist.Add(peer.Handler.Close())
+	}
+
 	if peer.Mapper != nil {
 		errlist.Add(peer.Mapper.Close())
 	}
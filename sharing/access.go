@@ -20,7 +20,7 @@ import (
 //
 // It returns an error if the access grant is correctly encoded but it doesn't
 // parse or if the Auth Service responds with an error.
-func parseAccess(ctx context.Context, access string, cfg AuthServiceConfig, clientIP string) (_ *uplink.Access, err error) {
+func parseAccess(ctx context.Context, access string, auth *AuthServiceClient, clientIP string) (_ *uplink.Access, err error) {
 	defer mon.Task()(&ctx)(&err)
 	wrappedParse := func(access string) (*uplink.Access, error) {
 		parsed, err := uplink.ParseAccess(access)
@@ -36,7 +36,7 @@ func parseAccess(ctx context.Context, access string, cfg AuthServiceConfig, clie
 	}
 
 	// otherwise, assume an access key.
-	authResp, err := cfg.Resolve(ctx, access, clientIP)
+	authResp, err := auth.Resolve(ctx, access, clientIP)
 	if err != nil {
 		return nil, err
 	}
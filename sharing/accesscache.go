@@ -0,0 +1,182 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+
+	"storj.io/uplink"
+)
+
+// AccessCacheConfig configures the cache of parsed access grants and of the
+// access grants the auth service resolves access keys into.
+type AccessCacheConfig struct {
+	// Capacity is the number of accesses that are cached. zero disables the
+	// cache.
+	Capacity int
+	// TTL is how long parsed and resolved accesses are cached.
+	TTL time.Duration
+	// NegativeTTL is how long access keys that the auth service doesn't know
+	// or refuses to resolve are cached. zero doesn't cache them.
+	NegativeTTL time.Duration
+}
+
+// cachedAccess is the result of parsing an access.
+type cachedAccess struct {
+	key        string
+	access     *uplink.Access
+	err        error
+	expiration time.Time
+}
+
+// accessCache is a least recently used cache of parsed accesses, keyed by
+// the hash of the serialized access grant or access key.
+type accessCache struct {
+	config AccessCacheConfig
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // of *cachedAccess, most recently used first
+
+	parseLocks MutexGroup
+}
+
+func newAccessCache(config AccessCacheConfig) *accessCache {
+	return &accessCache{
+		config:  config,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// accessCacheKey returns the key of access in the cache, which doesn't keep
+// the secrets in it.
+func accessCacheKey(access string) string {
+	sum := sha256.Sum256([]byte(access))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns the cached result of parsing access, or parses it with parse
+// and caches the result. concurrent requests for the same access wait for a
+// single parse.
+func (cache *accessCache) Get(ctx context.Context, access string, parse func(context.Context) (*uplink.Access, error)) (_ *uplink.Access, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	key := accessCacheKey(access)
+	if cached, ok := cache.lookup(key); ok {
+		return cached.access, cached.err
+	}
+
+	defer cache.parseLocks.Lock(key)()
+
+	// check if another request parsed it while we were waiting.
+	if cached, ok := cache.lookup(key); ok {
+		return cached.access, cached.err
+	}
+	mon.Event("access_cache_miss")
+
+	parsed, err := parse(ctx)
+	switch {
+	case err == nil:
+		cache.store(&cachedAccess{key: key, access: parsed, expiration: time.Now().Add(cache.config.TTL)})
+	case cache.config.NegativeTTL > 0 && negativelyCacheable(err):
+		cache.store(&cachedAccess{key: key, err: err, expiration: time.Now().Add(cache.config.NegativeTTL)})
+	}
+	return parsed, err
+}
+
+// negativelyCacheable reports whether err is an answer of the auth service
+// about the access key that will be the same for a while, as opposed to a
+// transient failure. a 401 rejects our own token, not the access key, so it
+// isn't cached.
+func negativelyCacheable(err error) bool {
+	switch GetStatus(err, 0) {
+	case http.StatusNotFound, http.StatusForbidden:
+		return true
+	}
+	return false
+}
+
+// lookup returns the unexpired cache entry of key.
+func (cache *accessCache) lookup(key string) (*cachedAccess, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	element, ok := cache.entries[key]
+	if !ok {
+		return nil, false
+	}
+	cached := element.Value.(*cachedAccess)
+	if time.Now().After(cached.expiration) {
+		mon.Event("access_cache_expired")
+		cache.remove(element)
+		return nil, false
+	}
+
+	cache.order.MoveToFront(element)
+	if cached.err != nil {
+		mon.Event("access_cache_negative_hit")
+	} else {
+		mon.Event("access_cache_hit")
+	}
+	return cached, true
+}
+
+// store caches the entry, evicting the least recently used ones beyond the
+// capacity.
+func (cache *accessCache) store(cached *cachedAccess) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if element, ok := cache.entries[cached.key]; ok {
+		cache.remove(element)
+	}
+	cache.entries[cached.key] = cache.order.PushFront(cached)
+	for cache.order.Len() > cache.config.Capacity {
+		mon.Event("access_cache_evicted")
+		cache.remove(cache.order.Back())
+	}
+}
+
+// remove removes the element from the cache. cache.mu must be held.
+func (cache *accessCache) remove(element *list.Element) {
+	delete(cache.entries, element.Value.(*cachedAccess).key)
+	cache.order.Remove(element)
+}
+
+// PurgeAll removes all accesses from the cache.
+func (cache *accessCache) PurgeAll() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.entries = make(map[string]*list.Element)
+	cache.order.Init()
+}
+
+// parseAccess parses or resolves access, from the access cache if it's
+// enabled.
+func (handler *Handler) parseAccess(ctx context.Context, access string, clientIP string) (_ *uplink.Access, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	if handler.accesses == nil {
+		return parseAccess(ctx, access, handler.auth, clientIP)
+	}
+	return handler.accesses.Get(ctx, access, func(ctx context.Context) (*uplink.Access, error) {
+		return parseAccess(ctx, access, handler.auth, clientIP)
+	})
+}
+
+// PurgeAccesses empties the access cache, so that accesses are parsed and
+// resolved again, e.g. after access keys were revoked.
+func (handler *Handler) PurgeAccesses() {
+	if handler.accesses != nil {
+		handler.accesses.PurgeAll()
+	}
+}
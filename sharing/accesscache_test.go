@@ -0,0 +1,98 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/errs"
+
+	"storj.io/common/testcontext"
+	"storj.io/uplink"
+)
+
+func TestAccessCache(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	cache := newAccessCache(AccessCacheConfig{
+		Capacity:    2,
+		TTL:         time.Hour,
+		NegativeTTL: time.Hour,
+	})
+
+	access := testAccess(t)
+	var parses int
+	parse := func(result *uplink.Access, err error) func(context.Context) (*uplink.Access, error) {
+		return func(context.Context) (*uplink.Access, error) {
+			parses++
+			return result, err
+		}
+	}
+
+	t.Run("positive", func(t *testing.T) {
+		parses = 0
+		for i := 0; i < 3; i++ {
+			got, err := cache.Get(ctx, "grant", parse(access, nil))
+			require.NoError(t, err)
+			assert.Same(t, access, got)
+		}
+		assert.Equal(t, 1, parses)
+
+		cache.PurgeAll()
+		_, err := cache.Get(ctx, "grant", parse(access, nil))
+		require.NoError(t, err)
+		assert.Equal(t, 2, parses)
+	})
+
+	t.Run("negative", func(t *testing.T) {
+		parses = 0
+		notFound := WithStatus(errs.New("unknown access key"), http.StatusNotFound)
+		for i := 0; i < 3; i++ {
+			_, err := cache.Get(ctx, "unknown", parse(nil, notFound))
+			require.Error(t, err)
+			assert.Equal(t, http.StatusNotFound, GetStatus(err, 0))
+		}
+		assert.Equal(t, 1, parses)
+
+		// transient failures aren't cached.
+		parses = 0
+		unavailable := WithStatus(errs.New("auth service is down"), http.StatusInternalServerError)
+		for i := 0; i < 3; i++ {
+			_, err := cache.Get(ctx, "transient", parse(nil, unavailable))
+			require.Error(t, err)
+		}
+		assert.Equal(t, 3, parses)
+	})
+
+	t.Run("capacity and expiration", func(t *testing.T) {
+		cache.PurgeAll()
+		for _, key := range []string{"a", "b", "c"} {
+			_, err := cache.Get(ctx, key, parse(access, nil))
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 2, cache.order.Len())
+		_, ok := cache.lookup(accessCacheKey("a"))
+		assert.False(t, ok, "least recently used access is evicted")
+
+		cache.config.TTL = -time.Second
+		_, err := cache.Get(ctx, "d", parse(access, nil))
+		require.NoError(t, err)
+		_, ok = cache.lookup(accessCacheKey("d"))
+		assert.False(t, ok, "expired access isn't returned")
+	})
+}
+
+func TestNegativelyCacheable(t *testing.T) {
+	assert.True(t, negativelyCacheable(WithStatus(errs.New("unknown"), http.StatusNotFound)))
+	assert.True(t, negativelyCacheable(WithStatus(errs.New("refused"), http.StatusForbidden)))
+	assert.False(t, negativelyCacheable(WithStatus(errs.New("bad token"), http.StatusUnauthorized)))
+	assert.False(t, negativelyCacheable(WithStatus(errs.New("unavailable"), http.StatusServiceUnavailable)))
+	assert.False(t, negativelyCacheable(errs.New("no status")))
+}
@@ -0,0 +1,303 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+
+	"storj.io/common/memory"
+	"storj.io/uplink"
+)
+
+// ArchiveConfig configures downloading whole prefixes as archives. a zero
+// value for either limit disables archive downloads.
+type ArchiveConfig struct {
+	// MaxObjects is the maximum number of objects in an archive.
+	MaxObjects int
+
+	// MaxSize is the maximum total size of the objects in an archive.
+	MaxSize memory.Size
+}
+
+// Enabled reports whether archive downloads are allowed.
+func (config ArchiveConfig) Enabled() bool {
+	return config.MaxObjects > 0 && config.MaxSize > 0
+}
+
+// archiveEntry is an object to include in an archive.
+type archiveEntry struct {
+	key     string // the object key in the bucket
+	name    string // the path of the entry in the archive
+	size    int64
+	created time.Time
+}
+
+// archiveFormat writes archive entries to a stream.
+type archiveFormat interface {
+	// WriteEntry adds an entry to the archive, returning the writer for its
+	// contents. the contents of directory entries must not be written.
+	WriteEntry(entry archiveEntry) (io.Writer, error)
+	// Close finishes the archive.
+	Close() error
+}
+
+// archiveFormats maps the values of the download query parameter to the
+// archive formats they select.
+var archiveFormats = map[string]struct {
+	extension   string
+	contentType string
+	new         func(w io.Writer) archiveFormat
+}{
+	"zip": {
+		extension:   ".zip",
+		contentType: "application/zip",
+		new:         newZipArchive,
+	},
+	"tar": {
+		extension:   ".tar",
+		contentType: "application/x-tar",
+		new:         newTarArchive,
+	},
+	"tgz": {
+		extension:   ".tar.gz",
+		contentType: "application/gzip",
+		new:         newTarGzipArchive,
+	},
+}
+
+// zipArchive writes a ZIP archive.
+type zipArchive struct {
+	zw *zip.Writer
+}
+
+func newZipArchive(w io.Writer) archiveFormat {
+	zw := zip.NewWriter(w)
+	// favor throughput over ratio, since a lot of shared data is already
+	// compressed.
+	zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(out, flate.BestSpeed)
+	})
+	return &zipArchive{zw: zw}
+}
+
+func (archive *zipArchive) WriteEntry(entry archiveEntry) (io.Writer, error) {
+	header := &zip.FileHeader{
+		Name:     entry.name,
+		Modified: entry.created,
+		Method:   zip.Deflate,
+	}
+	if strings.HasSuffix(entry.name, "/") {
+		header.Method = zip.Store
+	}
+	return archive.zw.CreateHeader(header)
+}
+
+func (archive *zipArchive) Close() error { return archive.zw.Close() }
+
+// tarArchive writes a POSIX tar archive, optionally gzipped.
+type tarArchive struct {
+	tw *tar.Writer
+	gz *gzip.Writer // nil if the archive isn't gzipped
+}
+
+func newTarArchive(w io.Writer) archiveFormat {
+	return &tarArchive{tw: tar.NewWriter(w)}
+}
+
+func newTarGzipArchive(w io.Writer) archiveFormat {
+	gz := gzip.NewWriter(w)
+	return &tarArchive{tw: tar.NewWriter(gz), gz: gz}
+}
+
+func (archive *tarArchive) WriteEntry(entry archiveEntry) (io.Writer, error) {
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     entry.name,
+		Size:     entry.size,
+		Mode:     0644,
+		ModTime:  entry.created,
+		Format:   tar.FormatPAX,
+	}
+	if strings.HasSuffix(entry.name, "/") {
+		header.Typeflag = tar.TypeDir
+		header.Size = 0
+		header.Mode = 0755
+	}
+	if err := archive.tw.WriteHeader(header); err != nil {
+		return nil, err
+	}
+	return archive.tw, nil
+}
+
+func (archive *tarArchive) Close() error {
+	err := archive.tw.Close()
+	if archive.gz != nil {
+		err = errs.Combine(err, archive.gz.Close())
+	}
+	return err
+}
+
+// archiveEntryName returns the path of the object in the archive, relative to
+// the downloaded prefix. ok is false if the key can't be safely represented,
+// e.g. because it would escape the directory the archive is extracted to.
+// some extractors on Windows treat backslashes as separators and honor drive
+// letters, so those are rejected too.
+func archiveEntryName(prefix, key string) (name string, ok bool) {
+	name = strings.TrimPrefix(key, prefix)
+	name = strings.TrimLeft(name, "/")
+	if name == "" || hasDrivePrefix(name) {
+		return "", false
+	}
+	for _, element := range strings.Split(name, "/") {
+		if element == "." || element == ".." || strings.Contains(element, `\`) {
+			return "", false
+		}
+	}
+	return name, true
+}
+
+// hasDrivePrefix reports whether name starts with a colon or a Windows drive
+// letter, like "C:".
+func hasDrivePrefix(name string) bool {
+	switch colon := strings.IndexByte(name, ':'); colon {
+	case 0:
+		return true
+	case 1:
+		c := name[0]
+		return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+	}
+	return false
+}
+
+// archiveName returns the file name to suggest for an archive of the
+// requested prefix.
+func archiveName(pr *parsedRequest) string {
+	name := path.Base(strings.TrimSuffix(pr.realKey, "/"))
+	if pr.realKey == "" || name == "." || name == "/" {
+		name = pr.bucket
+	}
+	return name
+}
+
+// listArchive lists the objects under the requested prefix, recursively,
+// checking them against the configured limits.
+func (handler *Handler) listArchive(ctx context.Context, project *uplink.Project, pr *parsedRequest) (entries []archiveEntry, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	objects := project.ListObjects(ctx, pr.bucket, &uplink.ListObjectsOptions{
+		Prefix:    pr.realKey,
+		Recursive: true,
+		System:    true,
+	})
+
+	var total int64
+	for objects.Next() {
+		item := objects.Item()
+		name, ok := archiveEntryName(pr.realKey, item.Key)
+		if !ok {
+			handler.log.Debug("skipping object unsafe for archives", zap.String("key", item.Key))
+			continue
+		}
+
+		total += item.System.ContentLength
+		if len(entries) >= handler.archive.MaxObjects || total > handler.archive.MaxSize.Int64() {
+			return nil, WithStatus(errs.New("archive exceeds limits of %d objects or %s",
+				handler.archive.MaxObjects, handler.archive.MaxSize), http.StatusRequestEntityTooLarge)
+		}
+
+		entries = append(entries, archiveEntry{
+			key:     item.Key,
+			name:    name,
+			size:    item.System.ContentLength,
+			created: item.System.Created,
+		})
+	}
+	if err := objects.Err(); err != nil {
+		return nil, WithAction(err, "list objects")
+	}
+	if len(entries) == 0 {
+		return nil, WithAction(uplink.ErrObjectNotFound, "serve archive - empty")
+	}
+	return entries, nil
+}
+
+// serveArchive streams every object under the requested prefix as an archive
+// in the given format. nothing is buffered beyond the listing, so once
+// streaming has started failures can only be logged.
+func (handler *Handler) serveArchive(ctx context.Context, w http.ResponseWriter, project *uplink.Project, pr *parsedRequest, format string) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	archiveFormat, ok := archiveFormats[format]
+	if !ok {
+		return WithStatus(errs.New("unknown archive format %q", format), http.StatusBadRequest)
+	}
+	if !handler.archive.Enabled() {
+		return WithStatus(errs.New("archive downloads are disabled"), http.StatusForbidden)
+	}
+
+	entries, err := handler.listArchive(ctx, project, pr)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", archiveFormat.contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": archiveName(pr) + archiveFormat.extension,
+	}))
+
+	archive := archiveFormat.new(w)
+	err = func() error {
+		for _, entry := range entries {
+			if err := handler.writeArchiveEntry(ctx, archive, project, pr.bucket, entry); err != nil {
+				return err
+			}
+		}
+		return archive.Close()
+	}()
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			handler.log.Error("unable to stream archive", zap.Error(err))
+		}
+	}
+	return nil
+}
+
+func (handler *Handler) writeArchiveEntry(ctx context.Context, archive archiveFormat, project *uplink.Project, bucket string, entry archiveEntry) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	contents, err := archive.WriteEntry(entry)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(entry.name, "/") || entry.size == 0 {
+		return nil
+	}
+
+	download, err := project.DownloadObject(ctx, bucket, entry.key, nil)
+	if err != nil {
+		return WithAction(err, "download object")
+	}
+	defer func() {
+		if err := download.Close(); err != nil {
+			handler.log.With(zap.Error(err)).Warn("unable to close download")
+		}
+	}()
+
+	_, err = io.Copy(contents, download)
+	return err
+}
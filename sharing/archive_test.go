@@ -0,0 +1,199 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/testcontext"
+	"storj.io/linksharing/objectmap"
+	"storj.io/uplink"
+)
+
+func TestArchiveEntryName(t *testing.T) {
+	for _, test := range []struct {
+		prefix, key string
+		name        string
+		ok          bool
+	}{
+		{prefix: "", key: "file.txt", name: "file.txt", ok: true},
+		{prefix: "dir/", key: "dir/file.txt", name: "file.txt", ok: true},
+		{prefix: "dir/", key: "dir/sub/file.txt", name: "sub/file.txt", ok: true},
+		{prefix: "dir/", key: "dir/sub/", name: "sub/", ok: true},
+		{prefix: "dir/", key: "dir//file.txt", name: "file.txt", ok: true},
+		{prefix: "dir/", key: "dir/", ok: false},
+		{prefix: "dir/", key: "dir/../file.txt", ok: false},
+		{prefix: "dir/", key: "dir/sub/../../file.txt", ok: false},
+		{prefix: "dir/", key: "dir/./file.txt", ok: false},
+		{prefix: "dir/", key: `dir/..\..\evil.exe`, ok: false},
+		{prefix: "dir/", key: `dir/sub\file.txt`, ok: false},
+		{prefix: "", key: `C:\x`, ok: false},
+		{prefix: "", key: "C:x", ok: false},
+		{prefix: "dir/", key: "dir/c:/x", ok: false},
+		{prefix: "", key: ":stream", ok: false},
+		{prefix: "", key: "logs/10:00.log", name: "logs/10:00.log", ok: true},
+		{prefix: "", key: "10:00.log", name: "10:00.log", ok: true},
+	} {
+		name, ok := archiveEntryName(test.prefix, test.key)
+		assert.Equal(t, test.ok, ok, test.key)
+		assert.Equal(t, test.name, name, test.key)
+	}
+}
+
+func TestArchiveName(t *testing.T) {
+	assert.Equal(t, "bucket", archiveName(&parsedRequest{bucket: "bucket"}))
+	assert.Equal(t, "dir", archiveName(&parsedRequest{bucket: "bucket", realKey: "dir/"}))
+	assert.Equal(t, "sub", archiveName(&parsedRequest{bucket: "bucket", realKey: "dir/sub/"}))
+}
+
+func TestZipArchive(t *testing.T) {
+	created := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	files := map[string]string{
+		"a.txt":     "hello",
+		"sub/b.txt": "world",
+	}
+
+	var buf bytes.Buffer
+	archive := newZipArchive(&buf)
+	for _, name := range []string{"a.txt", "sub/", "sub/b.txt"} {
+		contents, err := archive.WriteEntry(archiveEntry{
+			name:    name,
+			size:    int64(len(files[name])),
+			created: created,
+		})
+		require.NoError(t, err)
+		_, err = contents.Write([]byte(files[name]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, archive.Close())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 3)
+	for _, file := range zr.File {
+		require.True(t, file.Modified.Equal(created), file.Name)
+		if file.FileInfo().IsDir() {
+			require.Equal(t, "sub/", file.Name)
+			continue
+		}
+		rc, err := file.Open()
+		require.NoError(t, err)
+		data, err := ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		require.Equal(t, files[file.Name], string(data))
+	}
+}
+
+func TestTarArchive(t *testing.T) {
+	created := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	files := map[string]string{
+		"a.txt":     "hello",
+		"sub/b.txt": "world",
+	}
+
+	for _, gzipped := range []bool{false, true} {
+		var buf bytes.Buffer
+		archive := newTarArchive(&buf)
+		if gzipped {
+			archive = newTarGzipArchive(&buf)
+		}
+		for _, name := range []string{"a.txt", "sub/", "sub/b.txt"} {
+			contents, err := archive.WriteEntry(archiveEntry{
+				name:    name,
+				size:    int64(len(files[name])),
+				created: created,
+			})
+			require.NoError(t, err)
+			if files[name] != "" {
+				_, err = contents.Write([]byte(files[name]))
+				require.NoError(t, err)
+			}
+		}
+		require.NoError(t, archive.Close())
+
+		var reader io.Reader = &buf
+		if gzipped {
+			gz, err := gzip.NewReader(&buf)
+			require.NoError(t, err)
+			reader = gz
+		}
+
+		tr := tar.NewReader(reader)
+		var names []string
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			names = append(names, header.Name)
+			require.True(t, header.ModTime.Equal(created), header.Name)
+
+			if header.Typeflag == tar.TypeDir {
+				require.Equal(t, "sub/", header.Name)
+				continue
+			}
+			require.Equal(t, int64(len(files[header.Name])), header.Size)
+			data, err := ioutil.ReadAll(tr)
+			require.NoError(t, err)
+			require.Equal(t, files[header.Name], string(data))
+		}
+		require.Equal(t, []string{"a.txt", "sub/", "sub/b.txt"}, names)
+	}
+}
+
+func TestServeArchiveDisabled(t *testing.T) {
+	cfg := Config{
+		URLBases:  []string{"http://test.test"},
+		Templates: "../web",
+	}
+
+	handler, err := NewHandler(&zap.Logger{}, &objectmap.IPDB{}, cfg)
+	require.NoError(t, err)
+
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	w := httptest.NewRecorder()
+	err = handler.serveArchive(ctx, w, &uplink.Project{}, &parsedRequest{bucket: "bucket"}, "zip")
+	require.Error(t, err)
+	require.Equal(t, http.StatusForbidden, GetStatus(err, 0))
+
+	err = handler.serveArchive(ctx, w, &uplink.Project{}, &parsedRequest{bucket: "bucket"}, "rar")
+	require.Error(t, err)
+	require.Equal(t, http.StatusBadRequest, GetStatus(err, 0))
+}
+
+func TestPrefixListingArchiveButtons(t *testing.T) {
+	handler, err := NewHandler(zaptest.NewLogger(t), &objectmap.IPDB{}, Config{
+		URLBases:  []string{"http://test.test"},
+		Templates: "../web",
+	})
+	require.NoError(t, err)
+
+	for _, archives := range []bool{false, true} {
+		w := httptest.NewRecorder()
+		handler.renderTemplate(w, "prefix-listing.html", pageData{
+			Data:  map[string]interface{}{"Title": "bucket", "Archives": archives},
+			Title: "bucket",
+		})
+		require.Equal(t, archives, strings.Contains(w.Body.String(), "?download=zip"), "archives %v", archives)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenFileCheckInterval is how often a token file is checked for changes.
+const tokenFileCheckInterval = time.Second
+
+// authServiceTLSConfig returns the TLS configuration of connections to the
+// auth service, or nil if the defaults are used.
+func authServiceTLSConfig(config AuthServiceConfig) (*tls.Config, error) {
+	if config.CertFile == "" && config.KeyFile == "" && config.CAFile == "" && config.ServerName == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: config.ServerName,
+	}
+
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, AuthServiceError.New("unable to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if config.CAFile != "" {
+		pem, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, AuthServiceError.New("unable to read CA bundle: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, AuthServiceError.New("no certificates in CA bundle %q", config.CAFile)
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// tokenFile is a token that is read from a file, and read again whenever the
+// file changes, so that it can be rotated without restarting.
+type tokenFile struct {
+	path string
+
+	mu      sync.Mutex
+	token   string
+	modTime time.Time
+	size    int64
+	checked time.Time
+}
+
+// newTokenFile reads the token in the file at path.
+func newTokenFile(path string) (*tokenFile, error) {
+	file := &tokenFile{path: path}
+	if err := file.reload(time.Now()); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// Token returns the current token. the file is checked for changes at most
+// once per tokenFileCheckInterval, and if reading it fails the last token
+// read is kept.
+func (file *tokenFile) Token() string {
+	file.mu.Lock()
+	defer file.mu.Unlock()
+
+	if now := time.Now(); now.Sub(file.checked) >= tokenFileCheckInterval {
+		if err := file.reload(now); err != nil {
+			mon.Event("auth_service_token_reload_failed")
+		}
+	}
+	return file.token
+}
+
+// reload reads the token again if the file changed. file.mu must be held,
+// unless the file isn't shared yet.
+func (file *tokenFile) reload(now time.Time) error {
+	file.checked = now
+
+	info, err := os.Stat(file.path)
+	if err != nil {
+		return AuthServiceError.New("unable to stat token file: %w", err)
+	}
+	if info.ModTime().Equal(file.modTime) && info.Size() == file.size {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(file.path)
+	if err != nil {
+		return AuthServiceError.New("unable to read token file: %w", err)
+	}
+	file.token = strings.TrimSpace(string(data))
+	file.modTime, file.size = info.ModTime(), info.Size()
+	return nil
+}
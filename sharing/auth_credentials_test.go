@@ -0,0 +1,173 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/testcontext"
+)
+
+// testCertificate is a certificate and its key, signed by parent or self
+// signed if parent is nil.
+type testCertificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCertificate(t *testing.T, template *x509.Certificate, parent *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCertificate{cert: cert, key: key, der: der}
+}
+
+// write writes the certificate and its key as PEM files in dir.
+func (c *testCertificate) write(t *testing.T, dir, name string) (certFile, keyFile string) {
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func (c *testCertificate) tls() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+func TestAuthServiceMutualTLS(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	ca := newTestCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	server := newTestCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "auth.internal"},
+		DNSNames:    []string{"auth.internal"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	client := newTestCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "linksharing"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "linksharing", r.TLS.PeerCertificates[0].Subject.CommonName)
+		assert.Equal(t, "Bearer rotated", r.Header.Get("Authorization"))
+		_, err := w.Write([]byte(`{"public":true,"access_grant":"ag"}`))
+		require.NoError(t, err)
+	}))
+	ts.TLS = &tls.Config{
+		Certificates: []tls.Certificate{server.tls()},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	dir := ctx.Dir("credentials")
+	caFile, _ := ca.write(t, dir, "ca")
+	certFile, keyFile := client.write(t, dir, "client")
+	tokenPath := filepath.Join(dir, "token")
+	require.NoError(t, ioutil.WriteFile(tokenPath, []byte("initial\n"), 0600))
+
+	config := AuthServiceConfig{
+		BaseURL:    ts.URL,
+		TokenFile:  tokenPath,
+		CertFile:   certFile,
+		KeyFile:    keyFile,
+		CAFile:     caFile,
+		ServerName: "auth.internal",
+	}
+	auth, err := NewAuthServiceClient(config)
+	require.NoError(t, err)
+	assert.Equal(t, "initial", auth.authorization())
+
+	// the token is rotated without creating a new client.
+	require.NoError(t, ioutil.WriteFile(tokenPath, []byte("rotated\n"), 0600))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(tokenPath, later, later))
+	auth.token.checked = time.Time{}
+
+	resp, err := auth.Resolve(ctx, "key", "192.168.1.50")
+	require.NoError(t, err)
+	assert.Equal(t, "ag", resp.AccessGrant)
+
+	// endpoints may pin a name of their own, which takes precedence.
+	pinned := config
+	pinned.BaseURL = ""
+	pinned.ServerName = "other.internal"
+	pinned.Endpoints = []string{"eu1=" + ts.URL + "#auth.internal"}
+	pinnedAuth, err := NewAuthServiceClient(pinned)
+	require.NoError(t, err)
+	resp, err = pinnedAuth.Resolve(ctx, "key", "192.168.1.50")
+	require.NoError(t, err)
+	assert.Equal(t, "ag", resp.AccessGrant)
+
+	// without the client certificate the auth service refuses us.
+	config.CertFile, config.KeyFile = "", ""
+	config.RetryBudget = 1
+	noCert, err := NewAuthServiceClient(config)
+	require.NoError(t, err)
+	_, err = noCert.Resolve(ctx, "key", "192.168.1.50")
+	require.Error(t, err)
+}
+
+func TestTokenFileKeepsLastToken(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	tokenPath := filepath.Join(ctx.Dir("token"), "token")
+	require.NoError(t, ioutil.WriteFile(tokenPath, []byte("token"), 0600))
+
+	file, err := newTokenFile(tokenPath)
+	require.NoError(t, err)
+
+	require.NoError(t, os.Remove(tokenPath))
+	file.checked = time.Time{}
+	assert.Equal(t, "token", file.Token())
+
+	_, err = newTokenFile(tokenPath)
+	require.Error(t, err)
+}
@@ -5,10 +5,14 @@ package sharing
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"net"
 	"net/http"
 	"net/url"
 	"path"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/zeebo/errs"
@@ -21,6 +25,63 @@ type AuthServiceConfig struct {
 
 	// Authorization token used for the auth service to resolve access key ids.
 	Token string
+
+	// TokenFile is a file to read the authorization token from instead. it's
+	// read again when it changes.
+	TokenFile string
+
+	// CertFile and KeyFile are the client certificate and its key, which are
+	// presented to the auth service for mutual TLS.
+	CertFile string
+	KeyFile  string
+
+	// CAFile is a bundle of the certificates of the CAs that the certificate
+	// of the auth service is verified with, instead of the system ones.
+	CAFile string
+
+	// ServerName is the name that the certificate of the auth service is
+	// verified for, instead of the host of its url. it applies to every
+	// endpoint that doesn't pin a name of its own.
+	ServerName string
+
+	// Endpoints are the base urls of more replicas of the auth service, which
+	// requests fail over to. they may be prefixed by the region of the
+	// replica and suffixed by the name its certificate is verified for, as
+	// in "region=url#server-name".
+	Endpoints []string
+
+	// Region is the region of this service. replicas of the auth service in
+	// it are preferred.
+	Region string
+
+	// Timeout is the timeout of each request to the auth service. zero means
+	// no timeout.
+	Timeout time.Duration
+
+	// MaxConnsPerHost limits the connections to the auth service. zero means
+	// no limit.
+	MaxConnsPerHost int
+
+	// MaxIdleConnsPerHost is the number of idle connections to the auth
+	// service that are kept open.
+	MaxIdleConnsPerHost int
+
+	// BreakerThreshold is the number of consecutive failures of a replica
+	// of the auth service after which requests to it fail fast. zero disables
+	// the circuit breaker.
+	BreakerThreshold int
+
+	// BreakerCooldown is how long requests to a failing replica of the auth
+	// service fail fast before it's tried again.
+	BreakerCooldown time.Duration
+
+	// RetryBudget is the number of retries of failed requests to the auth
+	// service that can be saved up. zero means retries aren't limited.
+	RetryBudget int
+
+	// RetryRatio is the number of retries each request to the auth service
+	// adds to the budget.
+	RetryRatio float64
 }
 
 // AuthServiceResponse is the struct representing the response from the auth service.
@@ -32,78 +93,266 @@ type AuthServiceResponse struct {
 // AuthServiceError wraps all the errors returned when resolving an access key.
 var AuthServiceError = errs.Class("auth service")
 
-// Resolve maps an access key into an auth service response. clientIP is the IP
-// of the client that originated the request and it's required to be sent to the
-// Auth Service.
+// Resolve maps an access key into an auth service response with a client
+// made for the single request. clientIP is the IP of the client that
+// originated the request and it's required to be sent to the Auth Service.
+//
+// Deprecated: connections, circuit breakers and retry budgets aren't shared
+// across calls. use NewAuthServiceClient for repeated requests.
 func (a AuthServiceConfig) Resolve(ctx context.Context, accessKeyID string, clientIP string) (_ *AuthServiceResponse, err error) {
 	defer mon.Task()(&ctx)(&err)
 
-	reqURL, err := url.Parse(a.BaseURL)
+	client, err := NewAuthServiceClient(a)
 	if err != nil {
-		return nil, WithStatus(AuthServiceError.Wrap(err),
-			http.StatusInternalServerError)
+		return nil, WithStatus(AuthServiceError.Wrap(err), http.StatusInternalServerError)
 	}
+	defer client.closeIdleConnections()
 
-	reqURL.Path = path.Join(reqURL.Path, "/v1/access", accessKeyID)
-	req, err := http.NewRequestWithContext(ctx, "GET", reqURL.String(), nil)
-	if err != nil {
-		return nil, WithStatus(AuthServiceError.Wrap(err),
-			http.StatusInternalServerError)
+	return client.Resolve(ctx, accessKeyID, clientIP)
+}
+
+// AuthServiceClient resolves access keys with the auth service. it's safe
+// for concurrent use, which is needed for its circuit breakers and retry
+// budget to see all requests.
+type AuthServiceClient struct {
+	config    AuthServiceConfig
+	token     *tokenFile             // nil if the token is configured directly
+	endpoints []*authServiceEndpoint // in order of preference
+	retries   *retryBudget
+	// backoff is the delay between rounds of retries, copied for each
+	// request.
+	backoff ExponentialBackoff
+}
+
+// authServiceEndpoint is a replica of the auth service.
+type authServiceEndpoint struct {
+	baseURL    string
+	region     string
+	serverName string // pinned for this endpoint, if any
+	client     *http.Client
+	// breaker tracks the health of the endpoint.
+	breaker *circuitBreaker
+}
+
+// parseAuthServiceEndpoint parses an endpoint of the form
+// "region=url#server-name", where the region and server name are optional.
+func parseAuthServiceEndpoint(endpoint string) (region, baseURL, serverName string) {
+	if i := strings.LastIndex(endpoint, "#"); i >= 0 {
+		endpoint, serverName = endpoint[:i], endpoint[i+1:]
 	}
-	req.Header.Set("Authorization", "Bearer "+a.Token)
-	req.Header.Set("Forwarded", "for="+clientIP)
+	if i := strings.Index(endpoint, "="); i >= 0 && !strings.ContainsAny(endpoint[:i], ":/") {
+		return endpoint[:i], endpoint[i+1:], serverName
+	}
+	return "", endpoint, serverName
+}
 
-	delay := ExponentialBackoff{
-		Min: 100 * time.Millisecond,
-		Max: 5 * time.Second,
+// NewAuthServiceClient creates a new auth service client.
+func NewAuthServiceClient(config AuthServiceConfig) (*AuthServiceClient, error) {
+	tlsConfig, err := authServiceTLSConfig(config)
+	if err != nil {
+		return nil, err
 	}
-	for {
-		resp, err := http.DefaultClient.Do(req)
+
+	var token *tokenFile
+	if config.TokenFile != "" {
+		token, err = newTokenFile(config.TokenFile)
 		if err != nil {
-			if !delay.Maxed() {
-				if err := delay.Wait(ctx); err != nil {
-					return nil, WithStatus(AuthServiceError.Wrap(err), httpStatusClientClosedRequest)
-				}
-				continue
-			}
-			return nil, WithStatus(AuthServiceError.Wrap(err),
-				http.StatusInternalServerError)
+			return nil, err
 		}
+	}
 
-		// Use an anonymous function for deferring the response close before the
-		// next retry and not pilling it up when the method returns.
-		retry, authResp, err := func() (retry bool, _ *AuthServiceResponse, _ error) {
-			defer func() { _ = resp.Body.Close() }()
+	var endpoints []*authServiceEndpoint
+	if config.BaseURL != "" || len(config.Endpoints) == 0 {
+		endpoints = append(endpoints, &authServiceEndpoint{baseURL: config.BaseURL})
+	}
+	for _, endpoint := range config.Endpoints {
+		region, baseURL, serverName := parseAuthServiceEndpoint(endpoint)
+		endpoints = append(endpoints, &authServiceEndpoint{baseURL: baseURL, region: region, serverName: serverName})
+	}
 
-			if resp.StatusCode == http.StatusInternalServerError {
-				return true, nil, nil // auth only returns this for unexpected issues
+	// endpoints share connections, unless they pin the name of their
+	// certificate, which needs a TLS configuration of their own.
+	client := newAuthServiceHTTPClient(config, tlsConfig)
+	for _, endpoint := range endpoints {
+		endpoint.breaker = newCircuitBreaker(config.BreakerThreshold, config.BreakerCooldown)
+		endpoint.client = client
+		if endpoint.serverName != "" {
+			pinned := &tls.Config{MinVersion: tls.VersionTLS12}
+			if tlsConfig != nil {
+				pinned = tlsConfig.Clone()
 			}
+			pinned.ServerName = endpoint.serverName
+			endpoint.client = newAuthServiceHTTPClient(config, pinned)
+		}
+	}
+	// endpoints in our region are preferred, otherwise the configured order
+	// is kept.
+	sort.SliceStable(endpoints, func(i, k int) bool {
+		return config.Region != "" && endpoints[i].region == config.Region && endpoints[k].region != config.Region
+	})
 
-			if resp.StatusCode != http.StatusOK {
-				return false, nil, WithStatus(
-					AuthServiceError.New("invalid status code: %d", resp.StatusCode),
-					resp.StatusCode)
-			}
+	return &AuthServiceClient{
+		config:    config,
+		token:     token,
+		endpoints: endpoints,
+		retries:   newRetryBudget(config.RetryBudget, config.RetryRatio),
+		backoff: ExponentialBackoff{
+			Min: 100 * time.Millisecond,
+			Max: 5 * time.Second,
+		},
+	}, nil
+}
 
-			var authResp AuthServiceResponse
-			if err := json.NewDecoder(resp.Body).Decode(&authResp); err != nil {
-				if !delay.Maxed() {
-					return true, nil, nil
-				}
-				return false, nil, WithStatus(AuthServiceError.Wrap(err),
-					http.StatusInternalServerError)
-			}
+// newAuthServiceHTTPClient returns an HTTP client for requests to the auth
+// service with the given TLS configuration.
+func newAuthServiceHTTPClient(config AuthServiceConfig, tlsConfig *tls.Config) *http.Client {
+	return &http.Client{
+		Timeout: config.Timeout,
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSClientConfig:       tlsConfig,
+			ForceAttemptHTTP2:     true,
+			MaxConnsPerHost:       config.MaxConnsPerHost,
+			MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+	}
+}
 
-			return false, &authResp, nil
-		}()
+// closeIdleConnections closes the idle connections to every endpoint.
+func (a *AuthServiceClient) closeIdleConnections() {
+	for _, endpoint := range a.endpoints {
+		endpoint.client.CloseIdleConnections()
+	}
+}
 
-		if retry {
-			if err := delay.Wait(ctx); err != nil {
-				return nil, WithStatus(AuthServiceError.Wrap(err), httpStatusClientClosedRequest)
-			}
+// authorization returns the token the auth service is authorized with.
+func (a *AuthServiceClient) authorization() string {
+	if a.token != nil {
+		return a.token.Token()
+	}
+	return a.config.Token
+}
+
+// pick returns the first endpoint from the index start on that isn't failing
+// fast, and its index, or nil if all of them are.
+func (a *AuthServiceClient) pick(start int) (int, *authServiceEndpoint) {
+	for i := 0; i < len(a.endpoints); i++ {
+		index := (start + i) % len(a.endpoints)
+		if a.endpoints[index].breaker.Allow() {
+			return index, a.endpoints[index]
+		}
+	}
+	return 0, nil
+}
+
+// Resolve maps an access key into an auth service response. clientIP is the IP
+// of the client that originated the request and it's required to be sent to the
+// Auth Service.
+//
+// failed requests are retried with the next endpoint, backing off once all of
+// them were tried.
+func (a *AuthServiceClient) Resolve(ctx context.Context, accessKeyID string, clientIP string) (_ *AuthServiceResponse, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	a.retries.Deposit()
+	delay := a.backoff
+
+	next := 0  // index of the endpoint to try
+	tried := 0 // endpoints tried since the last backoff
+	for {
+		index, endpoint := a.pick(next)
+		if endpoint == nil {
+			mon.Event("auth_service_fail_fast")
+			return nil, WithStatus(AuthServiceError.New("auth service is unavailable"),
+				http.StatusServiceUnavailable)
+		}
+
+		authResp, retry, err := a.resolve(ctx, endpoint, accessKeyID, clientIP, delay.Maxed())
+		if !retry {
+			return authResp, err
+		}
+
+		if !a.retries.Withdraw() {
+			return nil, WithStatus(AuthServiceError.New("retry budget exhausted"),
+				http.StatusServiceUnavailable)
+		}
+		next = (index + 1) % len(a.endpoints)
+		if tried++; tried < len(a.endpoints) {
+			mon.Event("auth_service_failover")
 			continue
 		}
+		tried = 0
+		if err := delay.Wait(ctx); err != nil {
+			return nil, WithStatus(AuthServiceError.Wrap(err), httpStatusClientClosedRequest)
+		}
+	}
+}
+
+// resolve makes a single request to resolve an access key with endpoint, and
+// reports whether it should be retried. transient failures aren't retried if
+// final is set.
+func (a *AuthServiceClient) resolve(ctx context.Context, endpoint *authServiceEndpoint, accessKeyID string, clientIP string, final bool) (_ *AuthServiceResponse, retry bool, err error) {
+	defer mon.Task()(&ctx)(&err)
 
-		return authResp, err
+	reqURL, err := url.Parse(endpoint.baseURL)
+	if err != nil {
+		endpoint.breaker.Cancel()
+		return nil, false, WithStatus(AuthServiceError.Wrap(err),
+			http.StatusInternalServerError)
+	}
+
+	reqURL.Path = path.Join(reqURL.Path, "/v1/access", accessKeyID)
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL.String(), nil)
+	if err != nil {
+		endpoint.breaker.Cancel()
+		return nil, false, WithStatus(AuthServiceError.Wrap(err),
+			http.StatusInternalServerError)
 	}
+	req.Header.Set("Authorization", "Bearer "+a.authorization())
+	req.Header.Set("Forwarded", "for="+clientIP)
+
+	resp, err := endpoint.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			// the request went away, which says nothing about the service.
+			endpoint.breaker.Cancel()
+			return nil, false, WithStatus(AuthServiceError.Wrap(err), httpStatusClientClosedRequest)
+		}
+		endpoint.breaker.Failure()
+		return nil, !final, WithStatus(AuthServiceError.Wrap(err),
+			http.StatusInternalServerError)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusInternalServerError {
+		endpoint.breaker.Failure()
+		// auth only returns this for unexpected issues
+		return nil, !final, WithStatus(
+			AuthServiceError.New("invalid status code: %d", resp.StatusCode),
+			http.StatusInternalServerError)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		endpoint.breaker.Success()
+		return nil, false, WithStatus(
+			AuthServiceError.New("invalid status code: %d", resp.StatusCode),
+			resp.StatusCode)
+	}
+
+	var authResp AuthServiceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&authResp); err != nil {
+		endpoint.breaker.Failure()
+		return nil, !final, WithStatus(AuthServiceError.Wrap(err),
+			http.StatusInternalServerError)
+	}
+
+	endpoint.breaker.Success()
+	return &authResp, false, nil
 }
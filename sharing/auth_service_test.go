@@ -9,8 +9,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"storj.io/common/testcontext"
@@ -27,7 +30,9 @@ func TestLoadUserRetry(t *testing.T) {
 		require.NoError(t, err)
 	}))
 	asc := AuthServiceConfig{BaseURL: ts.URL, Token: "token"}
-	asr, err := asc.Resolve(context.Background(), "fakeUser", "192.168.1.50")
+	client, err := NewAuthServiceClient(asc)
+	require.NoError(t, err)
+	asr, err := client.Resolve(context.Background(), "fakeUser", "192.168.1.50")
 	require.NoError(t, err)
 	require.Equal(t, "ag", asr.AccessGrant)
 	require.False(t, firstAttempt)
@@ -53,10 +58,11 @@ func TestAuthServiceConfig_Resolve(t *testing.T) {
 		}))
 		defer testServer.Close()
 
-		svc := AuthServiceConfig{
+		svc, err := NewAuthServiceClient(AuthServiceConfig{
 			BaseURL: testServer.URL,
 			Token:   token,
-		}
+		})
+		require.NoError(t, err)
 
 		ctx := testcontext.New(t)
 		defer ctx.Cleanup()
@@ -66,5 +72,62 @@ func TestAuthServiceConfig_Resolve(t *testing.T) {
 		require.NotNil(t, resp)
 		require.Equal(t, accessGrant, resp.AccessGrant, "response access grant")
 		require.True(t, resp.Public, "response access grant")
+
+		// the config resolves with a client of its own.
+		resp, err = AuthServiceConfig{BaseURL: testServer.URL, Token: token}.Resolve(ctx, accessKeyID, clientIP)
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.Equal(t, accessGrant, resp.AccessGrant, "response access grant")
 	})
 }
+
+func TestAuthServiceClientFailover(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	var downRequests int32
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&downRequests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer down.Close()
+
+	var upRequests int32
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&upRequests, 1)
+		_, err := w.Write([]byte(`{"public":true,"access_grant":"ag"}`))
+		require.NoError(t, err)
+	}))
+	defer up.Close()
+
+	client, err := NewAuthServiceClient(AuthServiceConfig{
+		Endpoints:        []string{"eu=" + up.URL, "us=" + down.URL},
+		Region:           "us",
+		BreakerThreshold: 1,
+		BreakerCooldown:  time.Hour,
+	})
+	require.NoError(t, err)
+	require.Len(t, client.endpoints, 2)
+	assert.Equal(t, "us", client.endpoints[0].region, "endpoints in our region are preferred")
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Resolve(ctx, "key", "192.168.1.50")
+		require.NoError(t, err)
+		assert.Equal(t, "ag", resp.AccessGrant)
+	}
+	assert.EqualValues(t, 1, atomic.LoadInt32(&downRequests), "failing endpoint is skipped")
+	assert.EqualValues(t, 3, atomic.LoadInt32(&upRequests))
+}
+
+func TestParseAuthServiceEndpoint(t *testing.T) {
+	for endpoint, expected := range map[string][3]string{
+		"https://auth.example.test":                       {"", "https://auth.example.test", ""},
+		"eu1=https://auth.example.test":                   {"eu1", "https://auth.example.test", ""},
+		"https://auth.example.test/?region=us":            {"", "https://auth.example.test/?region=us", ""},
+		"eu1=https://10.0.0.1:7000#auth-eu1.example.test": {"eu1", "https://10.0.0.1:7000", "auth-eu1.example.test"},
+		"https://auth.example.test/?region=us#auth.test":  {"", "https://auth.example.test/?region=us", "auth.test"},
+	} {
+		region, baseURL, serverName := parseAuthServiceEndpoint(endpoint)
+		assert.Equal(t, expected, [3]string{region, baseURL, serverName}, endpoint)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"sync"
+	"time"
+)
+
+// circuitBreaker stops calls to a failing service. it opens after threshold
+// consecutive failures, after which calls fail fast until cooldown passes.
+// then a single trial call is let through, which closes it again if it
+// succeeds.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int       // consecutive
+	openedAt time.Time // zero while closed
+	trial    bool      // whether a trial call is in progress
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// Allow reports whether a call may be made. calls that are allowed must
+// report their outcome with Success or Failure.
+func (breaker *circuitBreaker) Allow() bool {
+	if breaker.threshold <= 0 {
+		return true
+	}
+
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+
+	if breaker.openedAt.IsZero() {
+		return true
+	}
+	if breaker.trial || time.Since(breaker.openedAt) < breaker.cooldown {
+		return false
+	}
+	breaker.trial = true
+	return true
+}
+
+// Success records a successful call.
+func (breaker *circuitBreaker) Success() {
+	if breaker.threshold <= 0 {
+		return
+	}
+
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+
+	if !breaker.openedAt.IsZero() {
+		mon.Event("circuit_breaker_closed")
+	}
+	breaker.failures = 0
+	breaker.openedAt = time.Time{}
+	breaker.trial = false
+}
+
+// Cancel records a call whose outcome says nothing about the service, like
+// one whose caller went away.
+func (breaker *circuitBreaker) Cancel() {
+	if breaker.threshold <= 0 {
+		return
+	}
+
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+
+	breaker.trial = false
+}
+
+// Failure records a failed call.
+func (breaker *circuitBreaker) Failure() {
+	if breaker.threshold <= 0 {
+		return
+	}
+
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+
+	breaker.failures++
+	if breaker.trial || (breaker.openedAt.IsZero() && breaker.failures >= breaker.threshold) {
+		mon.Event("circuit_breaker_opened")
+		breaker.openedAt = time.Now()
+	}
+	breaker.trial = false
+}
+
+// retryBudget limits retries across all calls to a service, so that retries
+// can't multiply the load on it while it's failing. each call earns ratio
+// retries, up to max saved ones.
+type retryBudget struct {
+	ratio float64
+	max   float64
+
+	mu     sync.Mutex
+	tokens float64
+}
+
+func newRetryBudget(max int, ratio float64) *retryBudget {
+	return &retryBudget{ratio: ratio, max: float64(max), tokens: float64(max)}
+}
+
+// Deposit records a call.
+func (budget *retryBudget) Deposit() {
+	if budget.max <= 0 {
+		return
+	}
+
+	budget.mu.Lock()
+	defer budget.mu.Unlock()
+
+	budget.tokens += budget.ratio
+	if budget.tokens > budget.max {
+		budget.tokens = budget.max
+	}
+}
+
+// Withdraw reports whether a retry may be made, spending it if so.
+func (budget *retryBudget) Withdraw() bool {
+	if budget.max <= 0 {
+		return true
+	}
+
+	budget.mu.Lock()
+	defer budget.mu.Unlock()
+
+	if budget.tokens < 1 {
+		mon.Event("retry_budget_exhausted")
+		return false
+	}
+	budget.tokens--
+	return true
+}
@@ -0,0 +1,126 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/testcontext"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	breaker := newCircuitBreaker(3, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		require.True(t, breaker.Allow())
+		breaker.Failure()
+	}
+	require.True(t, breaker.Allow())
+	breaker.Success()
+
+	// successes reset the consecutive failures.
+	for i := 0; i < 3; i++ {
+		require.True(t, breaker.Allow())
+		breaker.Failure()
+	}
+	assert.False(t, breaker.Allow(), "open breaker fails fast")
+
+	// after the cooldown a single trial call is let through.
+	breaker.cooldown = 0
+	require.True(t, breaker.Allow())
+	assert.False(t, breaker.Allow())
+	breaker.Failure()
+
+	require.True(t, breaker.Allow())
+	breaker.Success()
+	assert.True(t, breaker.Allow())
+	assert.True(t, breaker.Allow(), "closed breaker allows concurrent calls")
+
+	disabled := newCircuitBreaker(0, time.Hour)
+	for i := 0; i < 10; i++ {
+		disabled.Failure()
+	}
+	assert.True(t, disabled.Allow())
+}
+
+func TestRetryBudget(t *testing.T) {
+	budget := newRetryBudget(2, 0.5)
+	assert.True(t, budget.Withdraw())
+	assert.True(t, budget.Withdraw())
+	assert.False(t, budget.Withdraw())
+
+	budget.Deposit()
+	assert.False(t, budget.Withdraw())
+	budget.Deposit()
+	assert.True(t, budget.Withdraw())
+
+	unlimited := newRetryBudget(0, 0)
+	for i := 0; i < 10; i++ {
+		assert.True(t, unlimited.Withdraw())
+	}
+}
+
+func TestAuthServiceClientFailFast(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	client, err := NewAuthServiceClient(AuthServiceConfig{
+		BaseURL:          ts.URL,
+		BreakerThreshold: 2,
+		BreakerCooldown:  time.Hour,
+		RetryBudget:      1,
+	})
+	require.NoError(t, err)
+
+	// the budget allows a single retry, which trips the breaker.
+	_, err = client.Resolve(ctx, "key", "192.168.1.50")
+	require.Error(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, GetStatus(err, 0))
+	assert.EqualValues(t, 2, atomic.LoadInt32(&requests))
+
+	_, err = client.Resolve(ctx, "key", "192.168.1.50")
+	require.Error(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, GetStatus(err, 0))
+	assert.EqualValues(t, 2, atomic.LoadInt32(&requests), "open breaker fails fast")
+}
+
+func TestAuthServiceClientGivesUp(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	// without a breaker or budget, only the backoff ends the retries.
+	client, err := NewAuthServiceClient(AuthServiceConfig{
+		BaseURL:          ts.URL,
+		BreakerThreshold: 0,
+		RetryBudget:      0,
+	})
+	require.NoError(t, err)
+	client.backoff = ExponentialBackoff{Min: time.Millisecond, Max: 8 * time.Millisecond}
+
+	_, err = client.Resolve(ctx, "key", "192.168.1.50")
+	require.Error(t, err)
+	assert.Equal(t, http.StatusInternalServerError, GetStatus(err, 0))
+	assert.EqualValues(t, 5, atomic.LoadInt32(&requests))
+}
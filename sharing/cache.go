@@ -0,0 +1,146 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+
+	"storj.io/common/ranger"
+	"storj.io/linksharing/objectcache"
+	"storj.io/linksharing/objectranger"
+	"storj.io/uplink"
+)
+
+// objectBody returns a ranger of the contents of o. small objects are read
+// whole, from the object cache if they are cached, and concurrent downloads
+// of them are shared.
+func (handler *Handler) objectBody(ctx context.Context, project *uplink.Project, pr *parsedRequest, o *uplink.Object) ranger.Ranger {
+	stream := objectranger.NewWithOptions(project, o, pr.bucket, objectranger.Options{
+		Parallelism: handler.download.Parallelism,
+		ChunkSize:   handler.download.ChunkSize.Int64(),
+		Retries:     handler.download.Retries,
+	})
+
+	size := o.System.ContentLength
+	cached := handler.cache != nil && handler.cache.Cacheable(size)
+	coalesced := handler.download.CoalesceMaxSize > 0 && size <= handler.download.CoalesceMaxSize.Int64()
+	if !cached && !coalesced {
+		return stream
+	}
+	return &smallObjectRanger{
+		log:    handler.log,
+		stream: stream,
+		read: func(ctx context.Context) ([]byte, error) {
+			return handler.smallObject(ctx, project, pr, o, cached)
+		},
+	}
+}
+
+// smallObjectRanger is a ranger of a small object that is read whole when a
+// range of it is first requested, so that requests answered without the
+// contents, like conditional or HEAD requests, don't download it.
+type smallObjectRanger struct {
+	log    *zap.Logger
+	stream ranger.Ranger // of the object, if it can't be read whole
+	read   func(ctx context.Context) ([]byte, error)
+
+	mu   sync.Mutex
+	data []byte
+}
+
+// Size returns the size of the object.
+func (small *smallObjectRanger) Size() int64 { return small.stream.Size() }
+
+// Range returns a reader of the given range of the object.
+func (small *smallObjectRanger) Range(ctx context.Context, offset, length int64) (_ io.ReadCloser, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	small.mu.Lock()
+	defer small.mu.Unlock()
+
+	if small.data == nil {
+		data, err := small.read(ctx)
+		if err != nil {
+			// the download is retried as a stream, which may still work.
+			small.log.Debug("unable to read small object", zap.Error(err))
+			return small.stream.Range(ctx, offset, length)
+		}
+		small.data = data
+	}
+	return ranger.ByteRanger(small.data).Range(ctx, offset, length)
+}
+
+// smallObject returns the contents of o, from the object cache if cached is
+// set. they are downloaded once for concurrent requests of o with the same
+// access, and cached for that access if cached is set.
+func (handler *Handler) smallObject(ctx context.Context, project *uplink.Project, pr *parsedRequest, o *uplink.Object, cached bool) (_ []byte, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	access, err := accessKey(pr)
+	if err != nil {
+		return nil, err
+	}
+
+	var key objectcache.Key
+	if cached {
+		key = objectcache.Key{
+			Access:  access,
+			Bucket:  pr.bucket,
+			Key:     o.Key,
+			Created: o.System.Created.UnixNano(),
+			Size:    o.System.ContentLength,
+		}
+		if data, ok := handler.cache.Get(key); ok {
+			return data, nil
+		}
+	}
+	v, err := handler.coalesce(ctx, downloadKey(access, pr, o), func() (interface{}, error) {
+		return handler.downloadObject(ctx, project, pr, o)
+	})
+	if err != nil {
+		return nil, err
+	}
+	data := v.([]byte)
+
+	if cached {
+		handler.cache.Put(key, data)
+	}
+	return data, nil
+}
+
+// downloadObject downloads the contents of o into memory.
+func (handler *Handler) downloadObject(ctx context.Context, project *uplink.Project, pr *parsedRequest, o *uplink.Object) (_ []byte, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	download, err := project.DownloadObject(ctx, pr.bucket, o.Key, nil)
+	if err != nil {
+		return nil, WithAction(err, "download object")
+	}
+	defer func() {
+		if err := download.Close(); err != nil {
+			handler.log.With(zap.Error(err)).Warn("unable to close object download")
+		}
+	}()
+
+	// the contents are cached as those of o, so they must not come from an
+	// object that replaced it.
+	if !objectranger.SameObject(o, download.Info()) {
+		return nil, objectranger.ErrObjectChanged
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(download, o.System.ContentLength+1))
+	if err != nil {
+		return nil, WithAction(err, "read object")
+	}
+	if int64(len(data)) != o.System.ContentLength {
+		return nil, errs.New("object changed since it was stat'd")
+	}
+	return data, nil
+}
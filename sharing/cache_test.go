@@ -0,0 +1,116 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/memory"
+	"storj.io/common/ranger"
+	"storj.io/common/ranger/httpranger"
+	"storj.io/common/testcontext"
+	"storj.io/common/testrand"
+	"storj.io/linksharing/objectcache"
+	"storj.io/uplink"
+)
+
+func TestObjectBodyConditional(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	cache, err := objectcache.New(objectcache.Config{MaxObjectSize: memory.MiB, MemorySize: memory.MiB})
+	require.NoError(t, err)
+	defer ctx.Check(cache.Close)
+
+	coalescing := &Handler{
+		log:      zaptest.NewLogger(t),
+		download: DownloadConfig{CoalesceMaxSize: memory.MiB},
+	}
+	caching := &Handler{
+		log:   zaptest.NewLogger(t),
+		cache: cache,
+	}
+	o := &uplink.Object{
+		Key:    "a.txt",
+		System: uplink.SystemMetadata{Created: time.Now(), ContentLength: 100},
+	}
+
+	// the project is nil, so serving fails if the object is downloaded.
+	for _, test := range []struct {
+		handler *Handler
+		method  string
+		header  http.Header
+		status  int
+	}{
+		{handler: coalescing, method: http.MethodGet, header: http.Header{"If-None-Match": {objectETag(o)}}, status: http.StatusNotModified},
+		{handler: coalescing, method: http.MethodHead, header: http.Header{}, status: http.StatusOK},
+		{handler: caching, method: http.MethodGet, header: http.Header{"If-None-Match": {objectETag(o)}}, status: http.StatusNotModified},
+		{handler: caching, method: http.MethodHead, header: http.Header{}, status: http.StatusOK},
+	} {
+		body := test.handler.objectBody(ctx, nil, &parsedRequest{bucket: "bucket"}, o)
+		_, small := body.(*smallObjectRanger)
+		require.True(t, small)
+
+		r := httptest.NewRequest(test.method, "http://test.test/a.txt", nil)
+		r.Header = test.header
+		w := httptest.NewRecorder()
+		w.Header().Set("ETag", objectETag(o))
+		httpranger.ServeContent(ctx, w, r, o.Key, o.System.Created, body)
+		assert.Equal(t, test.status, w.Code, test.method)
+	}
+}
+
+func TestSmallObjectRanger(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	data := testrand.BytesInt(100)
+
+	var reads int
+	small := &smallObjectRanger{
+		log:    zaptest.NewLogger(t),
+		stream: ranger.ByteRanger(data),
+		read: func(ctx context.Context) ([]byte, error) {
+			reads++
+			return data, nil
+		},
+	}
+	assert.Equal(t, int64(100), small.Size())
+	assert.Zero(t, reads)
+
+	for _, offset := range []int64{0, 50} {
+		r, err := small.Range(ctx, offset, 10)
+		require.NoError(t, err)
+		got, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		assert.Equal(t, data[offset:offset+10], got)
+	}
+	assert.Equal(t, 1, reads)
+
+	// objects that can't be read whole are streamed instead.
+	small = &smallObjectRanger{
+		log:    zaptest.NewLogger(t),
+		stream: ranger.ByteRanger(data),
+		read: func(ctx context.Context) ([]byte, error) {
+			return nil, errors.New("download failed")
+		},
+	}
+	r, err := small.Range(ctx, 90, 10)
+	require.NoError(t, err)
+	got, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, data[90:], got)
+}
@@ -0,0 +1,67 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+
+	"storj.io/uplink"
+)
+
+// coalesce runs fn once for concurrent calls with the same key, and shares
+// its result with all of them. calls that only failed because the request
+// that ran fn went away run fn themselves.
+func (handler *Handler) coalesce(ctx context.Context, key string, fn func() (interface{}, error)) (interface{}, error) {
+	v, err, shared := handler.flights.Do(key, fn)
+	if shared && ctx.Err() == nil &&
+		(errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+		mon.Event("coalesced_call_rerun")
+		return fn()
+	}
+	return v, err
+}
+
+// accessKey identifies the access of the request in coalescing keys, so that
+// requests only share results they are allowed to see.
+func accessKey(pr *parsedRequest) (string, error) {
+	serialized, err := pr.access.Serialize()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(serialized))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// statObject stats the object key, sharing the result with concurrent
+// requests for the same object with the same access.
+func (handler *Handler) statObject(ctx context.Context, project *uplink.Project, pr *parsedRequest, key string) (_ *uplink.Object, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	access, err := accessKey(pr)
+	if err != nil {
+		return project.StatObject(ctx, pr.bucket, key)
+	}
+
+	v, err := handler.coalesce(ctx, "stat\x00"+access+"\x00"+pr.bucket+"\x00"+key, func() (interface{}, error) {
+		return project.StatObject(ctx, pr.bucket, key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the object is copied, since callers may modify it.
+	o := *v.(*uplink.Object)
+	return &o, nil
+}
+
+// downloadKey identifies the download of the version o of an object in
+// coalescing keys.
+func downloadKey(access string, pr *parsedRequest, o *uplink.Object) string {
+	return "download\x00" + access + "\x00" + pr.bucket + "\x00" + o.Key + "\x00" +
+		strconv.FormatInt(o.System.Created.UnixNano(), 10) + "\x00" +
+		strconv.FormatInt(o.System.ContentLength, 10)
+}
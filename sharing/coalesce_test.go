@@ -0,0 +1,90 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/testcontext"
+)
+
+func TestCoalesce(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	handler := &Handler{}
+
+	t.Run("shared", func(t *testing.T) {
+		var calls int32
+		release := make(chan struct{})
+		fn := func() (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return "result", nil
+		}
+
+		var wg sync.WaitGroup
+		results := make([]interface{}, 10)
+		for i := range results {
+			i := i
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				v, err := handler.coalesce(ctx, "key", fn)
+				assert.NoError(t, err)
+				results[i] = v
+			}()
+		}
+		// let the calls pile up behind the first one.
+		for atomic.LoadInt32(&calls) == 0 {
+			runtime.Gosched()
+		}
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		for _, v := range results {
+			assert.Equal(t, "result", v)
+		}
+		assert.Less(t, atomic.LoadInt32(&calls), int32(len(results)))
+	})
+
+	t.Run("canceled leader", func(t *testing.T) {
+		leaderCtx, cancel := context.WithCancel(ctx)
+		started := make(chan struct{})
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := handler.coalesce(leaderCtx, "canceled", func() (interface{}, error) {
+				close(started)
+				<-leaderCtx.Done()
+				return nil, leaderCtx.Err()
+			})
+			done <- err
+		}()
+		<-started
+
+		followerDone := make(chan interface{}, 1)
+		go func() {
+			v, err := handler.coalesce(ctx, "canceled", func() (interface{}, error) {
+				return "rerun", nil
+			})
+			assert.NoError(t, err)
+			followerDone <- v
+		}()
+
+		cancel()
+		require.True(t, errors.Is(<-done, context.Canceled))
+		assert.Equal(t, "rerun", <-followerDone)
+	})
+}
@@ -0,0 +1,174 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+
+	"storj.io/common/memory"
+)
+
+// CompressionConfig configures on-the-fly compression of responses.
+type CompressionConfig struct {
+	// Enabled turns on gzip and brotli compression of text-like responses
+	// for clients that accept it.
+	Enabled bool
+
+	// MaxSize is the largest response with a known length, such as an
+	// object body, that will be compressed. pages rendered by the handler
+	// itself have no known length and are always compressed.
+	MaxSize memory.Size
+}
+
+// compressMinSize is the smallest response with a known length that is worth
+// compressing.
+const compressMinSize = 256
+
+// brotliLevel trades compression ratio for speed, since we compress on
+// every request.
+const brotliLevel = 5
+
+// compressibleContentType reports whether responses of the given content
+// type are worth compressing.
+func compressibleContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "+xml") ||
+		strings.HasSuffix(mediaType, "+json") {
+		return true
+	}
+	switch mediaType {
+	case "application/javascript", "application/x-javascript", "application/ecmascript",
+		"application/json", "application/xml", "application/wasm",
+		"application/x-ndjson", "application/yaml", "application/x-yaml",
+		"application/toml", "application/x-sh", "application/csv":
+		return true
+	}
+	return false
+}
+
+// addVary adds value to the Vary header unless it's already there.
+func addVary(h http.Header, value string) {
+	for _, vary := range h["Vary"] {
+		for _, existing := range strings.Split(vary, ",") {
+			if strings.EqualFold(strings.TrimSpace(existing), value) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", value)
+}
+
+// compressResponseWriter compresses the response if, once the status and
+// headers are known, it turns out to be a complete, compressible response
+// that isn't already encoded. Close must be called to flush the encoder.
+type compressResponseWriter struct {
+	http.ResponseWriter
+
+	config CompressionConfig
+	coding string // the negotiated coding, empty if the client accepts none
+	head   bool   // HEAD responses get the same headers, but have no body
+
+	wroteHeader bool
+	encoder     io.WriteCloser // nil if the response isn't compressed
+}
+
+func newCompressResponseWriter(w http.ResponseWriter, r *http.Request, config CompressionConfig) *compressResponseWriter {
+	cw := &compressResponseWriter{
+		ResponseWriter: w,
+		config:         config,
+		head:           r.Method == http.MethodHead,
+	}
+	if codings := acceptedContentCodings(r.Header.Get("Accept-Encoding")); len(codings) > 0 {
+		cw.coding = codings[0].coding
+	}
+	return cw
+}
+
+// eligible reports whether a response with the given status and the current
+// headers may be compressed, regardless of what the client accepts.
+func (cw *compressResponseWriter) eligible(status int) bool {
+	h := cw.Header()
+	if status != http.StatusOK ||
+		h.Get("Content-Encoding") != "" ||
+		h.Get("Content-Range") != "" ||
+		!compressibleContentType(h.Get("Content-Type")) {
+		return false
+	}
+	if length := h.Get("Content-Length"); length != "" {
+		size, err := strconv.ParseInt(length, 10, 64)
+		if err != nil || size < compressMinSize || size > cw.config.MaxSize.Int64() {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteHeader decides whether to compress the response and writes the
+// header.
+func (cw *compressResponseWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.wroteHeader = true
+
+	if cw.eligible(status) {
+		h := cw.Header()
+		addVary(h, "Accept-Encoding")
+
+		if cw.coding != "" {
+			if !cw.head {
+				switch cw.coding {
+				case "br":
+					cw.encoder = brotli.NewWriterLevel(cw.ResponseWriter, brotliLevel)
+				case "gzip":
+					cw.encoder = gzip.NewWriter(cw.ResponseWriter)
+				}
+			}
+
+			h.Set("Content-Encoding", cw.coding)
+			h.Del("Content-Length")
+			// the compressed representation isn't byte-for-byte identical
+			// to the one a strong validator describes.
+			if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+				h.Set("ETag", "W/"+etag)
+			}
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes p to the response, compressing it if applicable.
+func (cw *compressResponseWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Close flushes and closes the encoder, if any.
+func (cw *compressResponseWriter) Close() error {
+	if cw.encoder == nil {
+		return nil
+	}
+	return cw.encoder.Close()
+}
@@ -0,0 +1,188 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/memory"
+)
+
+func TestCompressibleContentType(t *testing.T) {
+	for _, contentType := range []string{
+		"text/html; charset=utf-8",
+		"text/plain",
+		"image/svg+xml",
+		"application/javascript",
+		"application/json",
+		"application/ld+json",
+	} {
+		assert.True(t, compressibleContentType(contentType), contentType)
+	}
+	for _, contentType := range []string{
+		"",
+		"image/png",
+		"application/octet-stream",
+		"application/zip",
+		"video/mp4",
+		"not a content type;;",
+	} {
+		assert.False(t, compressibleContentType(contentType), contentType)
+	}
+}
+
+func TestCompressResponseWriter(t *testing.T) {
+	body := strings.Repeat("<p>highly compressible</p>\n", 100)
+	config := CompressionConfig{Enabled: true, MaxSize: memory.KiB * 4}
+
+	type response struct {
+		status  int
+		headers map[string]string
+		body    string
+	}
+
+	serve := func(method, acceptEncoding string, resp response) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "http://test.test/", nil)
+		if acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+
+		cw := newCompressResponseWriter(w, r, config)
+		for name, val := range resp.headers {
+			cw.Header().Set(name, val)
+		}
+		if resp.status != 0 {
+			cw.WriteHeader(resp.status)
+		}
+		_, err := cw.Write([]byte(resp.body))
+		require.NoError(t, err)
+		require.NoError(t, cw.Close())
+		return w
+	}
+
+	decode := func(t *testing.T, w *httptest.ResponseRecorder) string {
+		var reader io.Reader
+		switch w.Header().Get("Content-Encoding") {
+		case "gzip":
+			gz, err := gzip.NewReader(w.Body)
+			require.NoError(t, err)
+			reader = gz
+		case "br":
+			reader = brotli.NewReader(w.Body)
+		default:
+			reader = w.Body
+		}
+		data, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	html := map[string]string{"Content-Type": "text/html; charset=utf-8"}
+
+	t.Run("gzip", func(t *testing.T) {
+		w := serve("GET", "gzip", response{headers: html, body: body})
+		require.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		require.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+		require.Less(t, w.Body.Len(), len(body))
+		require.Equal(t, body, decode(t, w))
+	})
+
+	t.Run("brotli preferred", func(t *testing.T) {
+		w := serve("GET", "gzip, deflate, br", response{headers: html, body: body})
+		require.Equal(t, "br", w.Header().Get("Content-Encoding"))
+		require.Equal(t, body, decode(t, w))
+	})
+
+	t.Run("not accepted", func(t *testing.T) {
+		w := serve("GET", "", response{headers: html, body: body})
+		require.Empty(t, w.Header().Get("Content-Encoding"))
+		require.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+		require.Equal(t, body, w.Body.String())
+	})
+
+	t.Run("known length and weakened etag", func(t *testing.T) {
+		w := serve("GET", "gzip", response{
+			headers: map[string]string{
+				"Content-Type":   "text/plain",
+				"Content-Length": strconv.Itoa(len(body)),
+				"ETag":           `"abc"`,
+			},
+			status: http.StatusOK,
+			body:   body,
+		})
+		require.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		require.Empty(t, w.Header().Get("Content-Length"))
+		require.Equal(t, `W/"abc"`, w.Header().Get("ETag"))
+		require.Equal(t, body, decode(t, w))
+	})
+
+	t.Run("head", func(t *testing.T) {
+		w := serve("HEAD", "gzip", response{
+			headers: map[string]string{
+				"Content-Type":   "text/plain",
+				"Content-Length": strconv.Itoa(len(body)),
+			},
+			status: http.StatusOK,
+		})
+		require.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		require.Empty(t, w.Header().Get("Content-Length"))
+		require.Zero(t, w.Body.Len())
+	})
+
+	for _, test := range []struct {
+		name string
+		resp response
+	}{
+		{
+			name: "incompressible type",
+			resp: response{headers: map[string]string{"Content-Type": "image/png"}, body: body},
+		},
+		{
+			name: "already encoded",
+			resp: response{headers: map[string]string{"Content-Type": "text/plain", "Content-Encoding": "gzip"}, body: body},
+		},
+		{
+			name: "partial content",
+			resp: response{
+				headers: map[string]string{"Content-Type": "text/plain", "Content-Range": "bytes 0-9/100"},
+				status:  http.StatusPartialContent,
+				body:    body[:10],
+			},
+		},
+		{
+			name: "not ok",
+			resp: response{headers: html, status: http.StatusNotFound, body: body},
+		},
+		{
+			name: "too large",
+			resp: response{
+				headers: map[string]string{"Content-Type": "text/plain", "Content-Length": strconv.Itoa(2 * len(body))},
+				body:    body + body,
+			},
+		},
+		{
+			name: "too small",
+			resp: response{
+				headers: map[string]string{"Content-Type": "text/plain", "Content-Length": "5"},
+				body:    "hello",
+			},
+		},
+	} {
+		w := serve("GET", "br, gzip", test.resp)
+		assert.Equal(t, test.resp.headers["Content-Encoding"], w.Header().Get("Content-Encoding"), test.name)
+		assert.Equal(t, test.resp.body, w.Body.String(), test.name)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+
+	"storj.io/uplink"
+)
+
+// etagMetadataKeys are custom metadata keys that uploaders (e.g. the S3
+// gateway) use to store an etag-like value. the first non-empty one found is
+// mixed into the object's etag.
+var etagMetadataKeys = []string{"s3:etag", "etag", "md5"}
+
+// objectETag returns a strong ETag for the object. it is derived from the
+// object's identity: its key, creation time, content length and any stored
+// etag-like custom metadata, so a re-upload of the same key yields a new
+// ETag even if the content length doesn't change.
+func objectETag(o *uplink.Object) string {
+	h := sha256.New()
+
+	var buf [8]byte
+	writeField := func(b []byte) {
+		binary.BigEndian.PutUint64(buf[:], uint64(len(b)))
+		_, _ = h.Write(buf[:])
+		_, _ = h.Write(b)
+	}
+
+	writeField([]byte(o.Key))
+	binary.BigEndian.PutUint64(buf[:], uint64(o.System.Created.UnixNano()))
+	_, _ = h.Write(buf[:])
+	binary.BigEndian.PutUint64(buf[:], uint64(o.System.ContentLength))
+	_, _ = h.Write(buf[:])
+
+	for _, key := range etagMetadataKeys {
+		if val := strings.TrimSpace(o.Custom[key]); val != "" {
+			writeField([]byte(key))
+			writeField([]byte(val))
+			break
+		}
+	}
+
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
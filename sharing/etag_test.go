@@ -0,0 +1,106 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"storj.io/common/testcontext"
+	"storj.io/linksharing/objectmap"
+	"storj.io/uplink"
+)
+
+func TestObjectETag(t *testing.T) {
+	created := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	base := uplink.Object{
+		Key:    "images/pic.jpg",
+		System: uplink.SystemMetadata{Created: created, ContentLength: 1024},
+	}
+	etag := objectETag(&base)
+	require.Regexp(t, `^"[0-9a-f]{32}"$`, etag)
+	require.Equal(t, etag, objectETag(&base), "etag must be stable")
+
+	for _, test := range []struct {
+		name   string
+		modify func(o *uplink.Object)
+	}{
+		{"key", func(o *uplink.Object) { o.Key = "images/pic2.jpg" }},
+		{"created", func(o *uplink.Object) { o.System.Created = created.Add(time.Second) }},
+		{"size", func(o *uplink.Object) { o.System.ContentLength = 1025 }},
+		{"custom etag", func(o *uplink.Object) { o.Custom = uplink.CustomMetadata{"s3:etag": "abc"} }},
+		{"custom md5", func(o *uplink.Object) { o.Custom = uplink.CustomMetadata{"md5": "abc"} }},
+	} {
+		o := base
+		test.modify(&o)
+		require.NotEqual(t, etag, objectETag(&o), test.name)
+	}
+
+	o := base
+	o.Custom = uplink.CustomMetadata{"content-type": "image/jpeg"}
+	require.Equal(t, etag, objectETag(&o), "unrelated metadata must not change the etag")
+}
+
+func TestShowObjectConditionalGet(t *testing.T) {
+	cfg := Config{
+		URLBases:  []string{"http://test.test"},
+		Templates: "../web",
+	}
+
+	handler, err := NewHandler(&zap.Logger{}, &objectmap.IPDB{}, cfg)
+	require.NoError(t, err)
+
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	object := &uplink.Object{
+		Key: "test.jpg",
+		System: uplink.SystemMetadata{
+			Created:       time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC),
+			ContentLength: 1024,
+		},
+	}
+	etag := objectETag(object)
+
+	t.Run("matching If-None-Match", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequestWithContext(ctx, "GET", "http://test.test/raw/a/b/test.jpg", nil)
+		require.NoError(t, err)
+		r.Header.Set("If-None-Match", etag)
+
+		err = handler.showObject(ctx, w, r, &parsedRequest{}, &uplink.Project{}, object)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotModified, w.Code)
+		require.Equal(t, etag, w.Header().Get("ETag"))
+		require.Empty(t, w.Header().Get("Content-Type"))
+	})
+
+	t.Run("matching If-Modified-Since", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequestWithContext(ctx, "GET", "http://test.test/raw/a/b/test.jpg", nil)
+		require.NoError(t, err)
+		r.Header.Set("If-Modified-Since", object.System.Created.Format(http.TimeFormat))
+
+		err = handler.showObject(ctx, w, r, &parsedRequest{}, &uplink.Project{}, object)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotModified, w.Code)
+	})
+
+	t.Run("wrapped page is not conditional", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequestWithContext(ctx, "GET", "http://test.test/s/a/b/test.jpg", nil)
+		require.NoError(t, err)
+		r.Header.Set("If-None-Match", etag)
+
+		err = handler.showObject(ctx, w, r, &parsedRequest{wrapDefault: true}, &uplink.Project{}, object)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Empty(t, w.Header().Get("ETag"))
+	})
+}
@@ -17,8 +17,11 @@ import (
 	"github.com/spacemonkeygo/monkit/v3"
 	"github.com/zeebo/errs"
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 
+	"storj.io/common/memory"
 	"storj.io/common/rpc/rpcpool"
+	"storj.io/linksharing/objectcache"
 	"storj.io/linksharing/objectmap"
 	"storj.io/uplink"
 	"storj.io/uplink/private/transport"
@@ -30,6 +33,7 @@ var mon = monkit.Package()
 type pageData struct {
 	Data  interface{} // data to provide to the page
 	Title string      // <title> for the page
+	Meta  *pageMeta   // link preview metadata for the page, if any
 
 	// because we are serving data on someone else's domain, for our
 	// branded pages like file listing and the map view, all static assets
@@ -84,6 +88,42 @@ type Config struct {
 	// request, IP from headers).
 	ClientTrustedIPsList []string
 
+	// Compression configures on-the-fly compression of responses.
+	Compression CompressionConfig
+
+	// Archive configures downloading whole prefixes as archives.
+	Archive ArchiveConfig
+
+	// Preview configures previews of text objects in the wrapped object page.
+	Preview PreviewConfig
+
+	// Images configures resizing of image objects.
+	Images ImageConfig
+
+	// Download configures how object downloads are fetched from the network.
+	Download DownloadConfig
+
+	// Cache configures the cache of the contents of small objects.
+	Cache objectcache.Config
+
+	// ProjectPool configures the reuse of open projects across requests.
+	ProjectPool ProjectPoolConfig
+
+	// AccessCache configures the cache of parsed and resolved accesses.
+	AccessCache AccessCacheConfig
+
+	// ListPageSize is the number of entries in a page of a prefix listing.
+	ListPageSize int
+
+	// ListMaxScan is the maximum number of entries scanned for a page of a
+	// prefix listing that is filtered or sorted by anything but name. larger
+	// prefixes are only sorted partially, and filtered a part at a time.
+	ListMaxScan int
+
+	// ReadmeNames are the names of the Markdown objects rendered beneath
+	// prefix listings, in order of preference.
+	ReadmeNames []string
+
 	// UseClientIPHeaders indicates that the HTTP headers `Forwarded`,
 	// `X-Forwarded-Ip`, and `X-Real-Ip` (in this order) are used to get the
 	// client IP before falling back of getting from the client request.
@@ -100,6 +140,23 @@ type ConnectionPoolConfig struct {
 	IdleExpiration time.Duration
 }
 
+// DownloadConfig is a config struct for configuring object downloads.
+type DownloadConfig struct {
+	// Parallelism is the number of chunks of a large download that are
+	// fetched at the same time. less than 2 fetches downloads as a single
+	// stream.
+	Parallelism int
+	// ChunkSize is the size of the chunks of parallel downloads. it should
+	// divide the segment size, so chunks don't span segments.
+	ChunkSize memory.Size
+	// Retries is the number of times a download that fails mid-stream is
+	// resumed from where it stopped.
+	Retries int
+	// CoalesceMaxSize is the size of the largest object whose concurrent
+	// downloads are shared, which reads it whole into memory.
+	CoalesceMaxSize memory.Size
+}
+
 // Handler implements the link sharing HTTP handler.
 //
 // architecture: Service
@@ -109,12 +166,25 @@ type Handler struct {
 	templates            *template.Template
 	mapper               *objectmap.IPDB
 	txtRecords           *txtRecords
-	authConfig           AuthServiceConfig
+	auth                 *AuthServiceClient
 	static               http.Handler
 	redirectHTTPS        bool
 	landingRedirect      string
 	uplink               *uplink.Config
 	trustedClientIPsList trustedIPsList
+	compression          CompressionConfig
+	archive              ArchiveConfig
+	preview              PreviewConfig
+	images               ImageConfig
+	resizes              chan struct{}
+	download             DownloadConfig
+	cache                *objectcache.Cache
+	flights              singleflight.Group
+	projects             *projectPool
+	accesses             *accessCache
+	listPageSize         int
+	listMaxScan          int
+	readmeNames          []string
 }
 
 // NewHandler creates a new link sharing HTTP handler.
@@ -156,6 +226,26 @@ func NewHandler(log *zap.Logger, mapper *objectmap.IPDB, config Config) (*Handle
 		return nil, err
 	}
 
+	listPageSize := config.ListPageSize
+	if listPageSize <= 0 {
+		listPageSize = defaultListPageSize
+	}
+
+	listMaxScan := config.ListMaxScan
+	if listMaxScan <= 0 {
+		listMaxScan = defaultListMaxScan
+	}
+
+	readmeNames := config.ReadmeNames
+	if len(readmeNames) == 0 {
+		readmeNames = defaultReadmeNames
+	}
+
+	var resizes chan struct{}
+	if config.Images.MaxConcurrent > 0 {
+		resizes = make(chan struct{}, config.Images.MaxConcurrent)
+	}
+
 	var trustedClientIPs trustedIPsList
 	if config.UseClientIPHeaders {
 		if len(config.ClientTrustedIPsList) > 0 {
@@ -167,26 +257,83 @@ func NewHandler(log *zap.Logger, mapper *objectmap.IPDB, config Config) (*Handle
 		trustedClientIPs = newTrustedIPsListUntrustAll()
 	}
 
+	var cache *objectcache.Cache
+	if config.Cache.Enabled() {
+		cache, err = objectcache.New(config.Cache)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var projects *projectPool
+	if config.ProjectPool.Capacity > 0 {
+		projects = newProjectPool(log, uplinkConfig, config.ProjectPool)
+	}
+
+	auth, err := NewAuthServiceClient(config.AuthServiceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	var accesses *accessCache
+	if config.AccessCache.Capacity > 0 {
+		accesses = newAccessCache(config.AccessCache)
+	}
+
 	return &Handler{
 		log:                  log,
 		urlBases:             bases,
 		templates:            templates,
 		mapper:               mapper,
-		txtRecords:           newTxtRecords(config.TxtRecordTTL, dns, config.AuthServiceConfig),
-		authConfig:           config.AuthServiceConfig,
+		txtRecords:           newTxtRecords(config.TxtRecordTTL, dns, auth),
+		auth:                 auth,
 		static:               http.StripPrefix("/static/", http.FileServer(http.Dir(config.StaticSourcesPath))),
 		landingRedirect:      config.LandingRedirectTarget,
 		redirectHTTPS:        config.RedirectHTTPS,
 		uplink:               uplinkConfig,
 		trustedClientIPsList: trustedClientIPs,
+		compression:          config.Compression,
+		archive:              config.Archive,
+		preview:              config.Preview,
+		images:               config.Images,
+		resizes:              resizes,
+		download:             config.Download,
+		cache:                cache,
+		projects:             projects,
+		accesses:             accesses,
+		listPageSize:         listPageSize,
+		listMaxScan:          listMaxScan,
+		readmeNames:          readmeNames,
 	}, nil
 }
 
+// Close releases the resources of the handler.
+func (handler *Handler) Close() error {
+	var group errs.Group
+	if handler.projects != nil {
+		group.Add(handler.projects.Close())
+	}
+	if handler.cache != nil {
+		group.Add(handler.cache.Close())
+	}
+	return group.Err()
+}
+
 // ServeHTTP handles link sharing requests.
 func (handler *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	defer mon.Task()(&ctx)(nil)
 
+	if handler.compression.Enabled {
+		cw := newCompressResponseWriter(w, r, handler.compression)
+		defer func() {
+			if err := cw.Close(); err != nil {
+				handler.log.Debug("unable to close compressed response", zap.Error(err))
+			}
+		}()
+		w = cw
+	}
+
 	handlerErr := handler.serveHTTP(ctx, w, r)
 	if handlerErr == nil {
 		return
@@ -241,6 +388,14 @@ func (handler *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		case http.StatusBadRequest, http.StatusMethodNotAllowed:
 			message = "Malformed request. Please try again."
 			skipLog = true
+		case http.StatusRequestEntityTooLarge:
+			message = "Oops! Too much data requested for a single download."
+			skipLog = true
+		case http.StatusServiceUnavailable:
+			// these are failing fast while a dependency is down, so they
+			// would flood the logs.
+			message = "Oops! The service is temporarily unavailable. Please try again later."
+			skipLog = true
 		}
 	}
 
@@ -301,6 +456,8 @@ func (handler *Handler) serveHTTP(ctx context.Context, w http.ResponseWriter, r
 		return nil
 	case strings.HasPrefix(r.URL.Path, "/health/process"):
 		return handler.healthProcess(ctx, w, r)
+	case r.URL.Path == "/oembed":
+		return handler.serveOEmbed(ctx, w, r)
 	case handler.landingRedirect != "" && (r.URL.Path == "" || r.URL.Path == "/"):
 		http.Redirect(w, r, handler.landingRedirect, http.StatusSeeOther)
 		return nil
@@ -0,0 +1,78 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"storj.io/uplink"
+)
+
+// metadataHeaders is the allowlist of custom metadata keys that are served
+// as HTTP response headers. uploaders such as the S3 gateway store these
+// under their lowercased header names. content-type is honored too, but is
+// validated separately by objectContentType.
+var metadataHeaders = []string{
+	"Cache-Control",
+	"Content-Encoding",
+	"Content-Disposition",
+	"Content-Language",
+}
+
+// metadataLookup finds key in the object's custom metadata, ignoring case.
+func metadataLookup(custom uplink.CustomMetadata, key string) (string, bool) {
+	if val, ok := custom[strings.ToLower(key)]; ok {
+		return val, true
+	}
+	for k, val := range custom {
+		if strings.EqualFold(k, key) {
+			return val, true
+		}
+	}
+	return "", false
+}
+
+// validHeaderValue reports whether val is non-empty and safe to put in a
+// response header as-is.
+func validHeaderValue(val string) bool {
+	if val == "" {
+		return false
+	}
+	for i := 0; i < len(val); i++ {
+		if c := val[i]; (c < ' ' && c != '\t') || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// objectContentType returns the content type of the object, preferring a
+// valid content-type custom metadata value and falling back to guessing it
+// from the key's extension.
+func objectContentType(o *uplink.Object) string {
+	if val, ok := metadataLookup(o.Custom, "Content-Type"); ok && validHeaderValue(val) {
+		if _, _, err := mime.ParseMediaType(val); err == nil {
+			return val
+		}
+	}
+	if contentType := mime.TypeByExtension(filepath.Ext(o.Key)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// setObjectHeaders sets the response headers for serving the object's
+// contents: the allowlisted headers found in its custom metadata and its
+// content type.
+func setObjectHeaders(h http.Header, o *uplink.Object) {
+	for _, name := range metadataHeaders {
+		if val, ok := metadataLookup(o.Custom, name); ok && validHeaderValue(val) {
+			h.Set(name, val)
+		}
+	}
+	h.Set("Content-Type", objectContentType(o))
+}
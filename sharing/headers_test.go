@@ -0,0 +1,88 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"storj.io/uplink"
+)
+
+func TestSetObjectHeaders(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		key      string
+		custom   uplink.CustomMetadata
+		expected map[string]string
+	}{
+		{
+			name:     "extension guess",
+			key:      "test.jpg",
+			expected: map[string]string{"Content-Type": "image/jpeg"},
+		},
+		{
+			name:     "no extension",
+			key:      "test",
+			expected: map[string]string{"Content-Type": "application/octet-stream"},
+		},
+		{
+			name: "metadata content type wins",
+			key:  "bundle.js.gz",
+			custom: uplink.CustomMetadata{
+				"content-type":     "application/javascript",
+				"content-encoding": "gzip",
+			},
+			expected: map[string]string{
+				"Content-Type":     "application/javascript",
+				"Content-Encoding": "gzip",
+			},
+		},
+		{
+			name:     "extensionless key with metadata",
+			key:      "index",
+			custom:   uplink.CustomMetadata{"Content-Type": "text/html; charset=utf-8"},
+			expected: map[string]string{"Content-Type": "text/html; charset=utf-8"},
+		},
+		{
+			name:     "invalid content type falls back",
+			key:      "test.png",
+			custom:   uplink.CustomMetadata{"content-type": "not a/valid type;;"},
+			expected: map[string]string{"Content-Type": "image/png"},
+		},
+		{
+			name: "allowlisted headers",
+			key:  "doc.txt",
+			custom: uplink.CustomMetadata{
+				"cache-control":       "max-age=3600",
+				"content-disposition": `inline; filename="doc.txt"`,
+				"content-language":    "en",
+			},
+			expected: map[string]string{
+				"Content-Type":        "text/plain; charset=utf-8",
+				"Cache-Control":       "max-age=3600",
+				"Content-Disposition": `inline; filename="doc.txt"`,
+				"Content-Language":    "en",
+			},
+		},
+		{
+			name: "not allowlisted or unsafe",
+			key:  "doc.txt",
+			custom: uplink.CustomMetadata{
+				"set-cookie":    "session=1",
+				"cache-control": "max-age=1\r\nSet-Cookie: session=1",
+			},
+			expected: map[string]string{"Content-Type": "text/plain; charset=utf-8"},
+		},
+	} {
+		h := http.Header{}
+		setObjectHeaders(h, &uplink.Object{Key: test.key, Custom: test.custom})
+		assert.Len(t, h, len(test.expected), test.name)
+		for name, val := range test.expected {
+			assert.Equal(t, val, h.Get(name), test.name+": "+name)
+		}
+	}
+}
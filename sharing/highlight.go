@@ -0,0 +1,262 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"html/template"
+	"path"
+	"strings"
+)
+
+// syntax describes just enough of a language to highlight its comments,
+// strings, numbers and keywords.
+type syntax struct {
+	lineComments []string
+	blockComment [2]string
+	quotes       string // quote characters; a backtick may span lines
+	keywords     map[string]bool
+}
+
+func keywords(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
+
+var (
+	cSyntax = &syntax{
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+		keywords: keywords(`auto break case char const continue default do double else enum extern
+			float for goto if inline int long register return short signed sizeof static struct
+			switch typedef union unsigned void volatile while bool true false nullptr class namespace
+			public private protected template typename virtual new delete this using`),
+	}
+	goSyntax = &syntax{
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+		keywords: keywords(`break case chan const continue default defer else fallthrough for func
+			go goto if import interface map package range return select struct switch type var
+			true false nil iota`),
+	}
+	javaSyntax = &syntax{
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+		keywords: keywords(`abstract async await break case catch class const continue default
+			delete do else enum export extends final finally for function if implements import in
+			instanceof interface let new null package private protected public return static super
+			switch this throw throws try typeof var void while yield true false undefined fun val
+			override`),
+	}
+	rustSyntax = &syntax{
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"`,
+		keywords: keywords(`as async await break const continue crate dyn else enum extern false fn
+			for if impl in let loop match mod move mut pub ref return self Self static struct super
+			trait true type unsafe use where while`),
+	}
+	pythonSyntax = &syntax{
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+		keywords: keywords(`and as assert async await break class continue def del elif else except
+			finally for from global if import in is lambda nonlocal not or pass raise return try
+			while with yield True False None`),
+	}
+	rubySyntax = &syntax{
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+		keywords: keywords(`alias and begin break case class def defined do else elsif end ensure
+			false for if in module next nil not or redo rescue retry return self super then true
+			undef unless until when while yield require`),
+	}
+	shellSyntax = &syntax{
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+		keywords: keywords(`if then else elif fi case esac for while until do done in function
+			return exit export local readonly set unset source`),
+	}
+	sqlSyntax = &syntax{
+		lineComments: []string{"--"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `'"`,
+		keywords: keywords(`SELECT FROM WHERE INSERT INTO VALUES UPDATE SET DELETE CREATE TABLE
+			INDEX DROP ALTER ADD PRIMARY KEY FOREIGN REFERENCES NOT NULL AND OR IN IS AS JOIN LEFT
+			RIGHT INNER OUTER ON GROUP BY ORDER HAVING LIMIT OFFSET UNION ALL DISTINCT DEFAULT
+			select from where insert into values update set delete create table index drop alter
+			add primary key foreign references not null and or in is as join left right inner outer
+			on group by order having limit offset union all distinct default`),
+	}
+	dataSyntax = &syntax{
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+		keywords:     keywords(`true false null yes no on off True False Null`),
+	}
+	jsonSyntax = &syntax{
+		quotes:   `"`,
+		keywords: keywords(`true false null`),
+	}
+	markupSyntax = &syntax{
+		blockComment: [2]string{"<!--", "-->"},
+		quotes:       `"`,
+	}
+	cssSyntax = &syntax{
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+	}
+)
+
+// syntaxes maps file extensions, or whole names for files without one, to
+// their syntax.
+var syntaxes = map[string]*syntax{
+	".c": cSyntax, ".h": cSyntax, ".cc": cSyntax, ".cpp": cSyntax, ".hpp": cSyntax, ".cs": cSyntax,
+	".go":   goSyntax,
+	".java": javaSyntax, ".js": javaSyntax, ".mjs": javaSyntax, ".ts": javaSyntax, ".kt": javaSyntax,
+	".scala": javaSyntax, ".swift": javaSyntax, ".dart": javaSyntax, ".proto": javaSyntax,
+	".rs": rustSyntax,
+	".py": pythonSyntax,
+	".rb": rubySyntax,
+	".sh": shellSyntax, ".bash": shellSyntax, ".zsh": shellSyntax, "Dockerfile": shellSyntax, "Makefile": shellSyntax,
+	".sql":  sqlSyntax,
+	".yaml": dataSyntax, ".yml": dataSyntax, ".toml": dataSyntax, ".ini": dataSyntax, ".conf": dataSyntax,
+	".cfg": dataSyntax, ".env": dataSyntax, ".properties": dataSyntax,
+	".json": jsonSyntax, ".geojson": jsonSyntax,
+	".html": markupSyntax, ".htm": markupSyntax, ".xml": markupSyntax, ".svg": markupSyntax,
+	".css": cssSyntax, ".scss": cssSyntax,
+}
+
+// syntaxFor returns the syntax of the object with the given key, or nil if
+// it isn't known.
+func syntaxFor(key string) *syntax {
+	name := path.Base(key)
+	if s, ok := syntaxes[name]; ok {
+		return s
+	}
+	return syntaxes[strings.ToLower(path.Ext(name))]
+}
+
+// highlightSource returns source as HTML, with its comments, strings, numbers
+// and keywords wrapped in spans of the hl-c, hl-s, hl-n and hl-k classes. a
+// nil syntax only escapes it.
+func highlightSource(s *syntax, source string) template.HTML {
+	var b strings.Builder
+	emit := func(class, text string) {
+		if class == "" {
+			b.WriteString(template.HTMLEscapeString(text))
+			return
+		}
+		b.WriteString(`<span class="` + class + `">`)
+		b.WriteString(template.HTMLEscapeString(text))
+		b.WriteString(`</span>`)
+	}
+
+	if s == nil {
+		emit("", source)
+		return template.HTML(b.String()) //nolint: gosec // the source is escaped.
+	}
+
+	for i := 0; i < len(source); {
+		rest := source[i:]
+
+		if open := s.blockComment[0]; open != "" && strings.HasPrefix(rest, open) {
+			end := strings.Index(rest[len(open):], s.blockComment[1])
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += len(open) + len(s.blockComment[1])
+			}
+			emit("hl-c", rest[:end])
+			i += end
+			continue
+		}
+
+		if lineComment(s, rest) {
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			emit("hl-c", rest[:end])
+			i += end
+			continue
+		}
+
+		c := rest[0]
+		switch {
+		case strings.IndexByte(s.quotes, c) >= 0:
+			end := stringEnd(rest)
+			emit("hl-s", rest[:end])
+			i += end
+		case isDigit(c) && (i == 0 || !isIdentByte(source[i-1])):
+			end := 1
+			for end < len(rest) && (isIdentByte(rest[end]) || rest[end] == '.') {
+				end++
+			}
+			emit("hl-n", rest[:end])
+			i += end
+		case isIdentByte(c):
+			end := 1
+			for end < len(rest) && isIdentByte(rest[end]) {
+				end++
+			}
+			if s.keywords[rest[:end]] {
+				emit("hl-k", rest[:end])
+			} else {
+				emit("", rest[:end])
+			}
+			i += end
+		default:
+			end := 1
+			for end < len(rest) && !isIdentByte(rest[end]) && !isDigit(rest[end]) &&
+				strings.IndexByte(s.quotes, rest[end]) < 0 && !strings.ContainsAny(rest[end:end+1], "/#-<") {
+				end++
+			}
+			emit("", rest[:end])
+			i += end
+		}
+	}
+	return template.HTML(b.String()) //nolint: gosec // all source text is escaped.
+}
+
+// lineComment reports whether text starts with a line comment of s.
+func lineComment(s *syntax, text string) bool {
+	for _, prefix := range s.lineComments {
+		if strings.HasPrefix(text, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// stringEnd returns the length of the quoted string text starts with. only
+// backtick strings may span lines, so an unterminated string ends with its
+// line.
+func stringEnd(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch text[i] {
+		case quote:
+			return i + 1
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case '\n':
+			if quote != '`' {
+				return i
+			}
+		}
+	}
+	return len(text)
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+func isIdentByte(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || isDigit(c) || c >= 0x80
+}
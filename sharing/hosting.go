@@ -39,15 +39,11 @@ func (handler *Handler) handleHostingService(ctx context.Context, w http.Respons
 
 	bucket, key := determineBucketAndObjectKey(root, r.URL.Path)
 
-	project, err := handler.uplink.OpenProject(ctx, access)
+	project, release, err := handler.openProject(ctx, access)
 	if err != nil {
 		return WithAction(err, "open project")
 	}
-	defer func() {
-		if err := project.Close(); err != nil {
-			handler.log.With(zap.Error(err)).Warn("unable to close project")
-		}
-	}()
+	defer release()
 
 	visibleKey := strings.TrimPrefix(r.URL.Path, "/")
 	if visibleKey == "" {
@@ -57,13 +53,14 @@ func (handler *Handler) handleHostingService(ctx context.Context, w http.Respons
 	}
 
 	err = handler.presentWithProject(ctx, w, r, &parsedRequest{
-		access:      access,
-		bucket:      bucket,
-		realKey:     key,
-		visibleKey:  visibleKey,
-		title:       host,
-		root:        breadcrumb{Prefix: host, URL: "/"},
-		wrapDefault: false,
+		access:        access,
+		bucket:        bucket,
+		realKey:       key,
+		visibleKey:    visibleKey,
+		title:         host,
+		root:          breadcrumb{Prefix: host, URL: "/"},
+		wrapDefault:   false,
+		precompressed: true,
 	}, project)
 
 	// if the error is anything other than ObjectNotFound, return to normal
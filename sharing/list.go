@@ -5,34 +5,290 @@ package sharing
 
 import (
 	"context"
+	"encoding/json"
 	"html/template"
+	"mime"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
 
 	"storj.io/common/memory"
 	"storj.io/uplink"
 )
 
+// defaultListPageSize is the number of entries in a page of a prefix listing
+// if none is configured.
+const defaultListPageSize = 500
+
+// defaultListMaxScan is the maximum number of entries scanned for a page of
+// a prefix listing if none is configured.
+const defaultListMaxScan = 10000
+
+// listTimeFormat is how times are shown in prefix listings.
+const listTimeFormat = "2006-01-02 15:04 UTC"
+
+// maxListHistory is the number of previous page cursors kept in the URLs of a
+// paged listing. going back further than that returns to the first page.
+const maxListHistory = 20
+
 type breadcrumb struct {
 	Prefix string
 	URL    string
 }
 
-func (handler *Handler) servePrefix(ctx context.Context, w http.ResponseWriter, project *uplink.Project, pr *parsedRequest) (err error) {
+// jsonListing is the JSON representation of a prefix listing.
+type jsonListing struct {
+	Prefix  string              `json:"prefix"`
+	Objects []jsonListingObject `json:"objects"`
+	// Cursor is the value to pass as the cursor query parameter to get the
+	// next page. it's empty on the last page.
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// jsonListingObject is a single entry of a JSON listing.
+type jsonListingObject struct {
+	Key     string     `json:"key"`
+	Prefix  bool       `json:"prefix"`
+	Size    int64      `json:"size"`
+	Created *time.Time `json:"created,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// wantsJSON reports whether a JSON listing was requested, either with
+// ?format=json or by listing application/json before text/html in the Accept
+// header.
+func wantsJSON(r *http.Request) bool {
+	switch r.URL.Query().Get("format") {
+	case "json":
+		return true
+	case "html":
+		return false
+	}
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "application/json":
+			return true
+		case "text/html":
+			return false
+		}
+	}
+	return false
+}
+
+// listPrefix lists the entries directly under the requested prefix that
+// pass the filter of order, starting after cursor (relative to the prefix).
+// if limit is positive, at most limit entries are returned and next is the
+// cursor for the following page, empty if there are no more entries. if
+// maxScan is positive, at most maxScan entries are scanned for the filter;
+// partial reports whether the scan stopped there, in which case next
+// continues after the last scanned entry.
+func (handler *Handler) listPrefix(ctx context.Context, project *uplink.Project, pr *parsedRequest, cursor string, limit, maxScan int, order listOrder) (items []*uplink.Object, next string, partial bool, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	opts := &uplink.ListObjectsOptions{
+		Prefix: pr.realKey,
+		System: true,
+		Custom: true,
+	}
+	if cursor != "" {
+		opts.Cursor = pr.realKey + cursor
+	}
+
+	var last *uplink.Object // the last entry scanned
+	var scanned int
+	objects := project.ListObjects(ctx, pr.bucket, opts)
+	for objects.Next() {
+		if maxScan > 0 && scanned == maxScan {
+			next, partial = last.Key[len(pr.realKey):], true
+			break
+		}
+		item := objects.Item()
+		scanned++
+		if !order.match(item.Key[len(pr.realKey):]) {
+			last = item
+			continue
+		}
+		if limit > 0 && len(items) == limit {
+			next = items[len(items)-1].Key[len(pr.realKey):]
+			break
+		}
+		items = append(items, item)
+		last = item
+	}
+	if err := objects.Err(); err != nil {
+		return nil, "", false, WithAction(err, "list objects")
+	}
+
+	if scanned == 0 && cursor == "" {
+		return nil, "", false, WithAction(uplink.ErrObjectNotFound, "serve prefix - empty")
+	}
+	return items, next, partial, nil
+}
+
+// listPageURLs returns the relative URLs of the previous and next pages of a
+// listing, given the current query, the cursor of the current page and the
+// cursor of the next page. an empty URL means there is no such page. the
+// cursors of previous pages are kept in the prev query parameter, since
+// listings can only be iterated forward.
+func listPageURLs(q url.Values, cursor, next string) (prevURL, nextURL string) {
+	history := q["prev"]
+
+	if cursor != "" {
+		prevQuery := copyValues(q)
+		prevQuery.Del("cursor")
+		prevQuery.Del("prev")
+		if len(history) > 0 {
+			if prevCursor := history[len(history)-1]; prevCursor != "" {
+				prevQuery.Set("cursor", prevCursor)
+			}
+			if len(history) > 1 {
+				prevQuery["prev"] = history[:len(history)-1]
+			}
+		}
+		prevURL = "?" + prevQuery.Encode()
+	}
+
+	if next != "" {
+		nextHistory := append(append([]string(nil), history...), cursor)
+		if len(nextHistory) > maxListHistory {
+			nextHistory = nextHistory[len(nextHistory)-maxListHistory:]
+		}
+		nextQuery := copyValues(q)
+		nextQuery.Set("cursor", next)
+		nextQuery["prev"] = nextHistory
+		nextURL = "?" + nextQuery.Encode()
+	}
+
+	return prevURL, nextURL
+}
+
+// listSortURLs returns the relative URLs that sort the listing by each of the
+// columns, keeping the filter. sorting by the current column again reverses
+// the order.
+func listSortURLs(q url.Values, current listOrder) map[string]string {
+	urls := make(map[string]string, 3)
+	for _, sortBy := range []string{"name", "size", "date"} {
+		sortQuery := copyValues(q)
+		sortQuery.Del("cursor")
+		sortQuery.Del("prev")
+		sortQuery.Set("sort", sortBy)
+		if sortBy == current.sort && !current.desc {
+			sortQuery.Set("order", "desc")
+		} else {
+			sortQuery.Del("order")
+		}
+		urls[sortBy] = "?" + sortQuery.Encode()
+	}
+	return urls
+}
+
+// galleryView reports whether a listing of items is shown as a gallery,
+// either because it was requested with ?view=gallery or because most of the
+// entries are images and no other view was requested.
+func galleryView(q url.Values, items []*uplink.Object) bool {
+	switch q.Get("view") {
+	case "gallery":
+		return true
+	case "list":
+		return false
+	}
+	var images int
+	for _, item := range items {
+		if !item.IsPrefix && mediaClass(item) == "image" {
+			images++
+		}
+	}
+	return images > 0 && images*2 > len(items)
+}
+
+// listViewURLs returns the relative URLs that show the listing as a list and
+// as a gallery.
+func listViewURLs(q url.Values) (listURL, galleryURL string) {
+	viewQuery := copyValues(q)
+	viewQuery.Set("view", "list")
+	listURL = "?" + viewQuery.Encode()
+	viewQuery.Set("view", "gallery")
+	galleryURL = "?" + viewQuery.Encode()
+	return listURL, galleryURL
+}
+
+// copyValues returns a copy of q that can be modified independently.
+func copyValues(q url.Values) url.Values {
+	c := make(url.Values, len(q))
+	for k, v := range q {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
+
+func (handler *Handler) servePrefix(ctx context.Context, w http.ResponseWriter, r *http.Request, project *uplink.Project, pr *parsedRequest) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	if format := r.URL.Query().Get("download"); format != "" {
+		if _, ok := archiveFormats[format]; ok {
+			return handler.serveArchive(ctx, w, project, pr, format)
+		}
+	}
+
+	addVary(w.Header(), "Accept")
+	if wantsJSON(r) {
+		return handler.servePrefixJSON(ctx, w, r, project, pr)
+	}
+
+	q := r.URL.Query()
+	order, err := parseListOrder(q)
+	if err != nil {
+		return err
+	}
+
 	type Object struct {
-		Key    string
-		URL    template.URL
-		Size   string
-		Prefix bool
+		Key       string
+		URL       template.URL
+		Size      string
+		Prefix    bool
+		Created   string
+		Expires   string
+		Type      string
+		Image     bool
+		RawURL    template.URL
+		Thumbnail template.URL
 	}
 
 	var input struct {
 		Title       string
 		Breadcrumbs []breadcrumb
 		Objects     []Object
+		PrevURL     string
+		NextURL     string
+
+		Sort      string
+		Desc      bool
+		Filter    string
+		SortURLs  map[string]string
+		Truncated bool
+		Partial   bool
+		Searched  bool
+		Scanned   int
+
+		Gallery        bool
+		ListViewURL    string
+		GalleryViewURL string
+		QR             bool
+		Archives       bool
+
+		Readme template.HTML
 	}
 	input.Title = pr.title
+	input.Archives = handler.archive.Enabled()
 	input.Breadcrumbs = append(input.Breadcrumbs, pr.root)
 	if pr.visibleKey != "" {
 		trimmed := strings.TrimRight(pr.visibleKey, "/")
@@ -44,16 +300,55 @@ func (handler *Handler) servePrefix(ctx context.Context, w http.ResponseWriter,
 		}
 	}
 
-	input.Objects = make([]Object, 0)
+	// the README is only shown on the first page, and fetched while the
+	// prefix is listed.
+	var readme template.HTML
+	var readmeDone sync.WaitGroup
+	defer readmeDone.Wait()
+	if q.Get("cursor") == "" {
+		readmeDone.Add(1)
+		go func() {
+			defer readmeDone.Done()
+			var err error
+			readme, err = handler.readPrefixReadme(ctx, project, pr)
+			if err != nil {
+				handler.log.Debug("unable to read readme", zap.Error(err))
+			}
+		}()
+	}
 
-	objects := project.ListObjects(ctx, pr.bucket, &uplink.ListObjectsOptions{
-		Prefix: pr.realKey,
-		System: true,
-	})
+	var items []*uplink.Object
+	if order.native() {
+		cursor := q.Get("cursor")
+		var next string
+		items, next, input.Searched, err = handler.listPrefix(ctx, project, pr, cursor, handler.listPageSize, handler.listMaxScan, order)
+		if err != nil {
+			return err
+		}
+		input.PrevURL, input.NextURL = listPageURLs(q, cursor, next)
+		input.Scanned = handler.listMaxScan
+	} else {
+		// other orders can't be paged with cursors, so only the first page
+		// is shown, sorted from at most listMaxScan entries.
+		items, input.Truncated, input.Partial, err = handler.listPrefixSorted(ctx, project, pr, order, handler.listPageSize, handler.listMaxScan)
+		input.Scanned = handler.listMaxScan
+		if err != nil {
+			return err
+		}
+	}
 
-	// TODO add paging
-	for objects.Next() {
-		item := objects.Item()
+	input.Sort = order.sort
+	input.Desc = order.desc
+	input.Filter = q.Get("filter")
+	input.SortURLs = listSortURLs(q, order)
+	input.Gallery = galleryView(q, items)
+	input.ListViewURL, input.GalleryViewURL = listViewURLs(q)
+	// QR codes are served for standard requests only.
+	input.QR = pr.rawRoot != ""
+
+	var images []string
+	input.Objects = make([]Object, 0, len(items))
+	for _, item := range items {
 		key := item.Key[len(pr.realKey):]
 		var keyURL string
 		if item.IsPrefix {
@@ -62,25 +357,84 @@ func (handler *Handler) servePrefix(ctx context.Context, w http.ResponseWriter,
 			keyURL = url.PathEscape(key)
 		}
 
-		input.Objects = append(input.Objects, Object{
+		object := Object{
 			Key:    key,
 			URL:    template.URL(keyURL),
 			Size:   memory.Size(item.System.ContentLength).Base10String(),
 			Prefix: item.IsPrefix,
-		})
-	}
-	err = objects.Err()
-	if err != nil {
-		return WithAction(err, "list objects")
+		}
+		if !item.IsPrefix {
+			object.Created = item.System.Created.UTC().Format(listTimeFormat)
+			if !item.System.Expires.IsZero() {
+				object.Expires = item.System.Expires.UTC().Format(listTimeFormat)
+			}
+			if val, ok := metadataLookup(item.Custom, "Content-Type"); ok && validHeaderValue(val) {
+				object.Type = val
+			}
+			if mediaClass(item) == "image" {
+				object.Image = true
+				object.RawURL = template.URL(keyURL + "?wrap=0")
+				object.Thumbnail = object.RawURL
+				if handler.resizable(item) {
+					object.Thumbnail = template.URL(keyURL + "?" + galleryThumbnailQuery)
+				}
+				images = append(images, string(object.Thumbnail))
+			}
+		}
+		input.Objects = append(input.Objects, object)
 	}
 
-	if len(input.Objects) == 0 {
-		return WithAction(uplink.ErrObjectNotFound, "serve prefix - empty")
-	}
+	readmeDone.Wait()
+	input.Readme = readme
 
 	handler.renderTemplate(w, "prefix-listing.html", pageData{
 		Data:  input,
 		Title: pr.title,
+		Meta:  handler.prefixMeta(r, images),
 	})
 	return nil
 }
+
+func (handler *Handler) servePrefixJSON(ctx context.Context, w http.ResponseWriter, r *http.Request, project *uplink.Project, pr *parsedRequest) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	q := r.URL.Query()
+	order, err := parseListOrder(q)
+	if err != nil {
+		return err
+	}
+	if !order.native() {
+		// JSON listings are paged with cursors, which only work in name order.
+		return WithStatus(errs.New("JSON listings can only be sorted by name in ascending order"), http.StatusBadRequest)
+	}
+
+	items, next, _, err := handler.listPrefix(ctx, project, pr, q.Get("cursor"), handler.listPageSize, handler.listMaxScan, order)
+	if err != nil {
+		return err
+	}
+
+	listing := jsonListing{
+		Prefix:  pr.visibleKey,
+		Objects: make([]jsonListingObject, 0, len(items)),
+		Cursor:  next,
+	}
+	for _, item := range items {
+		object := jsonListingObject{
+			Key:    item.Key[len(pr.realKey):],
+			Prefix: item.IsPrefix,
+			Size:   item.System.ContentLength,
+		}
+		if !item.IsPrefix {
+			created := item.System.Created
+			object.Created = &created
+			if !item.System.Expires.IsZero() {
+				expires := item.System.Expires
+				object.Expires = &expires
+			}
+		}
+		listing.Objects = append(listing.Objects, object)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(listing)
+}
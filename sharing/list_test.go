@@ -0,0 +1,151 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/testcontext"
+	"storj.io/uplink"
+)
+
+func TestWantsJSON(t *testing.T) {
+	for _, test := range []struct {
+		url    string
+		accept string
+		json   bool
+	}{
+		{url: "/s/access/bucket/", json: false},
+		{url: "/s/access/bucket/?format=json", json: true},
+		{url: "/s/access/bucket/?format=html", accept: "application/json", json: false},
+		{url: "/s/access/bucket/", accept: "application/json", json: true},
+		{url: "/s/access/bucket/", accept: "application/json; charset=utf-8", json: true},
+		{url: "/s/access/bucket/", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", json: false},
+		{url: "/s/access/bucket/", accept: "*/*", json: false},
+		{url: "/s/access/bucket/", accept: "text/plain, application/json", json: true},
+	} {
+		r := httptest.NewRequest("GET", test.url, nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		assert.Equal(t, test.json, wantsJSON(r), test.url+" "+test.accept)
+	}
+}
+
+func TestListPageURLs(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		query        string
+		cursor, next string
+		prev, nextQ  string
+	}{
+		{
+			name: "single page",
+		},
+		{
+			name:  "first page",
+			next:  "b",
+			nextQ: "?cursor=b&prev=",
+		},
+		{
+			name:   "second page",
+			query:  "cursor=b&prev=",
+			cursor: "b",
+			next:   "d",
+			prev:   "?",
+			nextQ:  "?cursor=d&prev=&prev=b",
+		},
+		{
+			name:   "third and last page",
+			query:  "cursor=d&prev=&prev=b",
+			cursor: "d",
+			prev:   "?cursor=b&prev=",
+		},
+		{
+			name:   "other parameters are kept",
+			query:  "wrap=1&cursor=b&prev=",
+			cursor: "b",
+			next:   "d",
+			prev:   "?wrap=1",
+			nextQ:  "?cursor=d&prev=&prev=b&wrap=1",
+		},
+		{
+			name:   "lost history returns to the first page",
+			query:  "cursor=x",
+			cursor: "x",
+			prev:   "?",
+		},
+	} {
+		q, err := url.ParseQuery(test.query)
+		require.NoError(t, err)
+		prevURL, nextURL := listPageURLs(q, test.cursor, test.next)
+		assert.Equal(t, test.prev, prevURL, test.name)
+		assert.Equal(t, test.nextQ, nextURL, test.name)
+	}
+
+	// history is bounded.
+	q := url.Values{}
+	cursor := ""
+	for i := 0; i < 3*maxListHistory; i++ {
+		next := fmt.Sprintf("key%03d", i)
+		_, nextURL := listPageURLs(q, cursor, next)
+
+		var err error
+		q, err = url.ParseQuery(nextURL[1:])
+		require.NoError(t, err)
+		require.Equal(t, next, q.Get("cursor"))
+		require.LessOrEqual(t, len(q["prev"]), maxListHistory)
+		require.Equal(t, cursor, q["prev"][len(q["prev"])-1])
+		cursor = next
+	}
+	require.Len(t, q["prev"], maxListHistory)
+}
+
+func TestGalleryView(t *testing.T) {
+	images := []*uplink.Object{
+		{Key: "a.png"},
+		{Key: "b.jpg"},
+		{Key: "notes.txt"},
+		{Key: "sub/", IsPrefix: true},
+	}
+	files := []*uplink.Object{
+		{Key: "a.png"},
+		{Key: "notes.txt"},
+		{Key: "data.csv"},
+	}
+
+	assert.False(t, galleryView(url.Values{}, images))
+	images = append(images, &uplink.Object{Key: "c.webp"})
+	assert.True(t, galleryView(url.Values{}, images))
+	assert.False(t, galleryView(url.Values{"view": {"list"}}, images))
+
+	assert.False(t, galleryView(url.Values{}, files))
+	assert.True(t, galleryView(url.Values{"view": {"gallery"}}, files))
+	assert.False(t, galleryView(url.Values{}, nil))
+
+	listURL, galleryURL := listViewURLs(url.Values{"sort": {"size"}, "view": {"gallery"}})
+	assert.Equal(t, "?sort=size&view=list", listURL)
+	assert.Equal(t, "?sort=size&view=gallery", galleryURL)
+}
+
+func TestServePrefixJSONSort(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	handler := &Handler{}
+	for _, query := range []string{"sort=size", "sort=date", "order=desc"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/s/access/bucket/?format=json&"+query, nil)
+		err := handler.servePrefixJSON(ctx, w, r, nil, &parsedRequest{bucket: "bucket"})
+		require.Error(t, err, query)
+		assert.Equal(t, http.StatusBadRequest, GetStatus(err, 0), query)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"container/heap"
+	"context"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/zeebo/errs"
+
+	"storj.io/uplink"
+)
+
+// listOrder is how a prefix listing is sorted and filtered, as requested with
+// the sort, order and filter query parameters.
+type listOrder struct {
+	sort   string // one of "name", "size" or "date"
+	desc   bool
+	filter string // a glob over the entry names, empty for no filtering
+}
+
+// parseListOrder parses the sort, order and filter query parameters. unknown
+// values fall back to the defaults; an invalid filter is an error.
+func parseListOrder(q url.Values) (order listOrder, err error) {
+	order.sort = "name"
+	switch sortBy := q.Get("sort"); sortBy {
+	case "size", "date":
+		order.sort = sortBy
+	}
+	order.desc = q.Get("order") == "desc"
+
+	order.filter = strings.TrimSpace(q.Get("filter"))
+	if order.filter != "" {
+		if !strings.ContainsAny(order.filter, `*?[\`) {
+			// a plain word finds the entries containing it.
+			order.filter = "*" + order.filter + "*"
+		}
+		if _, err := path.Match(order.filter, ""); err != nil {
+			return order, WithStatus(errs.New("invalid filter %q: %w", order.filter, err), http.StatusBadRequest)
+		}
+	}
+	return order, nil
+}
+
+// native reports whether the order is the one listings are returned in, so
+// the listing can be paged with cursors.
+func (order listOrder) native() bool {
+	return order.sort == "name" && !order.desc
+}
+
+// match reports whether the entry with the given name, relative to the
+// listed prefix, passes the filter.
+func (order listOrder) match(name string) bool {
+	if order.filter == "" {
+		return true
+	}
+	matched, _ := path.Match(order.filter, strings.TrimSuffix(name, "/"))
+	return matched
+}
+
+// less reports whether a is listed before b. prefixes always come first,
+// sorted by name.
+func (order listOrder) less(a, b *uplink.Object) bool {
+	if a.IsPrefix != b.IsPrefix {
+		return a.IsPrefix
+	}
+
+	var cmp int
+	if !a.IsPrefix {
+		switch order.sort {
+		case "size":
+			cmp = compareInt64(a.System.ContentLength, b.System.ContentLength)
+		case "date":
+			cmp = compareInt64(a.System.Created.UnixNano(), b.System.Created.UnixNano())
+		}
+	}
+	if cmp == 0 {
+		cmp = strings.Compare(a.Key, b.Key)
+	}
+	if order.desc {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// listTop is a heap of the best listing entries seen so far, with the worst
+// of them on top so it can be evicted.
+type listTop struct {
+	order listOrder
+	items []*uplink.Object
+}
+
+func (top *listTop) Len() int           { return len(top.items) }
+func (top *listTop) Less(i, k int) bool { return top.order.less(top.items[k], top.items[i]) }
+func (top *listTop) Swap(i, k int)      { top.items[i], top.items[k] = top.items[k], top.items[i] }
+func (top *listTop) Push(x interface{}) { top.items = append(top.items, x.(*uplink.Object)) }
+func (top *listTop) Pop() (x interface{}) {
+	x, top.items = top.items[len(top.items)-1], top.items[:len(top.items)-1]
+	return x
+}
+
+// add offers item to the heap, keeping at most limit items. it reports
+// whether an item had to be left out.
+func (top *listTop) add(item *uplink.Object, limit int) (evicted bool) {
+	if len(top.items) < limit {
+		heap.Push(top, item)
+		return false
+	}
+	if top.order.less(item, top.items[0]) {
+		top.items[0] = item
+		heap.Fix(top, 0)
+	}
+	return true
+}
+
+// listPrefixSorted lists the first limit entries directly under the requested
+// prefix in the given order. since listings can only be iterated by key, the
+// prefix is scanned from the start, but only limit entries are kept in
+// memory. more reports whether there were entries past limit. at most maxScan
+// entries are scanned; partial reports whether the scan stopped there, in
+// which case only the entries scanned so far are sorted.
+func (handler *Handler) listPrefixSorted(ctx context.Context, project *uplink.Project, pr *parsedRequest, order listOrder, limit, maxScan int) (items []*uplink.Object, more, partial bool, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	top := &listTop{order: order}
+
+	objects := project.ListObjects(ctx, pr.bucket, &uplink.ListObjectsOptions{
+		Prefix: pr.realKey,
+		System: true,
+		Custom: true,
+	})
+	var scanned int
+	for objects.Next() {
+		if scanned >= maxScan {
+			partial, more = true, true
+			break
+		}
+		item := objects.Item()
+		scanned++
+		if !order.match(item.Key[len(pr.realKey):]) {
+			continue
+		}
+		if top.add(item, limit) {
+			more = true
+		}
+	}
+	if err := objects.Err(); err != nil {
+		return nil, false, false, WithAction(err, "list objects")
+	}
+	if scanned == 0 {
+		return nil, false, false, WithAction(uplink.ErrObjectNotFound, "serve prefix - empty")
+	}
+
+	items = top.items
+	sort.Slice(items, func(i, k int) bool { return order.less(items[i], items[k]) })
+	return items, more, partial, nil
+}
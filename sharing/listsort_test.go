@@ -0,0 +1,109 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"storj.io/uplink"
+)
+
+func TestParseListOrder(t *testing.T) {
+	for _, test := range []struct {
+		query  string
+		order  listOrder
+		native bool
+	}{
+		{query: "", order: listOrder{sort: "name"}, native: true},
+		{query: "sort=bogus&order=bogus", order: listOrder{sort: "name"}, native: true},
+		{query: "sort=name&order=desc", order: listOrder{sort: "name", desc: true}},
+		{query: "sort=size", order: listOrder{sort: "size"}},
+		{query: "sort=date&order=desc", order: listOrder{sort: "date", desc: true}},
+		{query: "filter=*.jpg", order: listOrder{sort: "name", filter: "*.jpg"}, native: true},
+		{query: "filter=+report+", order: listOrder{sort: "name", filter: "*report*"}, native: true},
+	} {
+		q, err := url.ParseQuery(test.query)
+		require.NoError(t, err)
+		order, err := parseListOrder(q)
+		require.NoError(t, err, test.query)
+		assert.Equal(t, test.order, order, test.query)
+		assert.Equal(t, test.native, order.native(), test.query)
+	}
+
+	_, err := parseListOrder(url.Values{"filter": {"[a-"}})
+	require.Error(t, err)
+	require.Equal(t, http.StatusBadRequest, GetStatus(err, 0))
+}
+
+func TestListOrderMatch(t *testing.T) {
+	order := listOrder{filter: "*.jpg"}
+	assert.True(t, order.match("pic.jpg"))
+	assert.False(t, order.match("pic.png"))
+	assert.False(t, order.match("sub/"))
+	assert.True(t, listOrder{filter: "s*"}.match("sub/"))
+	assert.True(t, listOrder{}.match("anything"))
+}
+
+func TestListOrderLess(t *testing.T) {
+	now := time.Now()
+	objects := []*uplink.Object{
+		{Key: "b/", IsPrefix: true},
+		{Key: "a/", IsPrefix: true},
+		{Key: "big", System: uplink.SystemMetadata{ContentLength: 300, Created: now.Add(-time.Hour)}},
+		{Key: "new", System: uplink.SystemMetadata{ContentLength: 200, Created: now}},
+		{Key: "old", System: uplink.SystemMetadata{ContentLength: 100, Created: now.Add(-2 * time.Hour)}},
+	}
+
+	sorted := func(order listOrder) (keys []string) {
+		items := append([]*uplink.Object(nil), objects...)
+		sort.Slice(items, func(i, k int) bool { return order.less(items[i], items[k]) })
+		for _, item := range items {
+			keys = append(keys, item.Key)
+		}
+		return keys
+	}
+
+	assert.Equal(t, []string{"a/", "b/", "big", "new", "old"}, sorted(listOrder{sort: "name"}))
+	assert.Equal(t, []string{"b/", "a/", "old", "new", "big"}, sorted(listOrder{sort: "name", desc: true}))
+	assert.Equal(t, []string{"a/", "b/", "old", "new", "big"}, sorted(listOrder{sort: "size"}))
+	assert.Equal(t, []string{"b/", "a/", "new", "big", "old"}, sorted(listOrder{sort: "date", desc: true}))
+}
+
+func TestListTop(t *testing.T) {
+	const limit = 10
+
+	var objects []*uplink.Object
+	for i := 0; i < 100; i++ {
+		objects = append(objects, &uplink.Object{
+			Key:    string(rune('a'+i%26)) + string(rune('a'+i/26)),
+			System: uplink.SystemMetadata{ContentLength: rand.Int63n(1000)},
+		})
+	}
+
+	order := listOrder{sort: "size", desc: true}
+	top := &listTop{order: order}
+	var evicted bool
+	for _, object := range objects {
+		if top.add(object, limit) {
+			evicted = true
+		}
+	}
+	require.True(t, evicted)
+	require.Len(t, top.items, limit)
+
+	expected := append([]*uplink.Object(nil), objects...)
+	sort.Slice(expected, func(i, k int) bool { return order.less(expected[i], expected[k]) })
+
+	got := top.items
+	sort.Slice(got, func(i, k int) bool { return order.less(got[i], got[k]) })
+	require.Equal(t, expected[:limit], got)
+}
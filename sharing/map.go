@@ -7,6 +7,11 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
 	"net/http"
 	"net/url"
 
@@ -76,6 +81,21 @@ func (handler *Handler) serveMap(ctx context.Context, w http.ResponseWriter, pr
 
 	width := queryIntLookup(q, "width", 800)
 
+	if q.Get("fmt") == "png" {
+		// link previews can't show SVGs, so they get the map as a PNG.
+		if width <= 0 || width > maxMapRasterWidth {
+			width = maxMapRasterWidth
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, rasterizeMap(m, width, width/2)); err != nil {
+			return WithAction(err, "png encode")
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Content-Length", fmt.Sprint(buf.Len()))
+		_, err = w.Write(buf.Bytes())
+		return err
+	}
+
 	w.Header().Set("Content-Type", "image/svg+xml")
 
 	var buf bytes.Buffer
@@ -110,3 +130,75 @@ func (handler *Handler) serveMap(ctx context.Context, w http.ResponseWriter, pr
 	_, err = w.Write(data)
 	return err
 }
+
+// maxMapRasterWidth is the width of the largest map rendered as PNG.
+const maxMapRasterWidth = 2048
+
+var (
+	mapBackground = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	mapLand       = color.RGBA{R: 0xd8, G: 0xdd, B: 0xe1, A: 0xff}
+	mapNode       = color.RGBA{R: 0x25, G: 0x82, B: 0xff, A: 0xff}
+)
+
+// rasterizeMap draws the map like dotworld.Map.EncodeSVG does, but to an
+// image.
+func rasterizeMap(m *dotworld.Map, width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(mapBackground), image.Point{}, draw.Src)
+
+	pc := dotworld.PlateCarre{
+		Width:  float32(width),
+		Height: float32(height),
+	}
+	locr := 0.5*math.Min(
+		float64(width)/float64(m.CountX),
+		float64(height)/float64(m.CountY),
+	) - 1
+
+	plot := func(loc *dotworld.Location, c color.RGBA) {
+		p := pc.Forward(loc.S2)
+
+		szf := float64(loc.Land)
+		if loc.Load > 0 {
+			szf = 1.1 + 2*float64(loc.Load)
+		}
+		fillCircle(img, float64(p.X), float64(p.Y), math.Max(locr*szf, 2), c)
+	}
+
+	// nodes are drawn over the land.
+	for _, loc := range m.Locations {
+		if loc.Load == 0 {
+			plot(loc, mapLand)
+		}
+	}
+	for _, loc := range m.Locations {
+		if loc.Load > 0 {
+			plot(loc, mapNode)
+		}
+	}
+	return img
+}
+
+// fillCircle draws an antialiased filled circle.
+func fillCircle(img *image.RGBA, cx, cy, r float64, c color.RGBA) {
+	bounds := image.Rect(int(cx-r-1), int(cy-r-1), int(cx+r+2), int(cy+r+2)).Intersect(img.Bounds())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			dist := math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy)
+			coverage := math.Min(math.Max(r+0.5-dist, 0), 1)
+			if coverage == 0 {
+				continue
+			}
+			bg := img.RGBAAt(x, y)
+			blend := func(a, b uint8) uint8 {
+				return uint8(float64(a)*(1-coverage) + float64(b)*coverage + 0.5)
+			}
+			img.SetRGBA(x, y, color.RGBA{
+				R: blend(bg.R, c.R),
+				G: blend(bg.G, c.G),
+				B: blend(bg.B, c.B),
+				A: 0xff,
+			})
+		}
+	}
+}
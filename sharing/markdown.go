@@ -0,0 +1,170 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"context"
+	"errors"
+	"html"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"github.com/russross/blackfriday/v2"
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+
+	"storj.io/common/memory"
+	"storj.io/uplink"
+)
+
+// renderMarkdown renders the Markdown source to HTML that is safe to embed
+// in our pages: raw HTML is dropped, and links and images are only kept if
+// they are relative or use a trusted scheme.
+func renderMarkdown(source []byte) template.HTML {
+	// renderers keep state while rendering, so they can't be shared.
+	renderer := &safeMarkdownRenderer{
+		HTMLRenderer: blackfriday.NewHTMLRenderer(blackfriday.HTMLRendererParameters{
+			Flags: blackfriday.SkipHTML |
+				blackfriday.NofollowLinks |
+				blackfriday.NoreferrerLinks |
+				blackfriday.NoopenerLinks |
+				blackfriday.HrefTargetBlank,
+		}),
+	}
+	rendered := blackfriday.Run(source, blackfriday.WithRenderer(renderer))
+	return template.HTML(rendered) //nolint: gosec // raw HTML and unsafe URLs are dropped by safeMarkdownRenderer.
+}
+
+// safeMarkdownRenderer is a blackfriday HTML renderer that drops links and
+// images with untrusted URLs.
+type safeMarkdownRenderer struct {
+	*blackfriday.HTMLRenderer
+}
+
+// RenderNode renders a single node, dropping unsafe links and images.
+func (r *safeMarkdownRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+	switch node.Type {
+	case blackfriday.Link:
+		// the renderer decodes character references in destinations once
+		// when writing them, so the URL is checked as it will be written.
+		// otherwise "javascript&#58;..." would pass as a relative URL.
+		if !safeMarkdownURL(html.UnescapeString(string(node.LinkData.Destination))) {
+			// keep the link text, but not the link itself.
+			return blackfriday.GoToNext
+		}
+	case blackfriday.Image:
+		dest, ok := markdownImageURL(html.UnescapeString(string(node.LinkData.Destination)))
+		if !ok {
+			return blackfriday.SkipChildren
+		}
+		// escaped, so that the renderer decoding it writes dest as is.
+		node.LinkData.Destination = []byte(html.EscapeString(dest))
+	}
+	return r.HTMLRenderer.RenderNode(w, node, entering)
+}
+
+// safeMarkdownURL reports whether a URL in rendered Markdown is relative or
+// uses a trusted scheme.
+func safeMarkdownURL(rawURL string) bool {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "", "http", "https", "mailto":
+		return true
+	}
+	return false
+}
+
+// markdownImageURL returns the URL to use for an image in rendered Markdown.
+// relative images refer to shared objects, so they are requested unwrapped
+// to get the image itself instead of our page around it.
+func markdownImageURL(rawURL string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return u.String(), true
+	case "":
+		if u.Host != "" {
+			return u.String(), true
+		}
+		if u.Path == "" {
+			return "", false
+		}
+		q := u.Query()
+		q.Set("wrap", "0")
+		u.RawQuery = q.Encode()
+		return u.String(), true
+	}
+	return "", false
+}
+
+// defaultReadmeNames are the names of the objects rendered beneath prefix
+// listings if none are configured.
+var defaultReadmeNames = []string{"README.md", "readme.md", "index.md"}
+
+// maxReadmeSize is the largest README that is rendered beneath a prefix
+// listing.
+const maxReadmeSize = 512 * memory.KiB
+
+// readPrefixReadme returns the rendered README of the requested prefix, the
+// first of the configured names that exists. it's empty if there is none.
+func (handler *Handler) readPrefixReadme(ctx context.Context, project *uplink.Project, pr *parsedRequest) (readme template.HTML, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	type statResult struct {
+		object *uplink.Object
+		err    error
+	}
+	results := make([]chan statResult, len(handler.readmeNames))
+	for i, name := range handler.readmeNames {
+		results[i] = make(chan statResult, 1)
+		go func(key string, result chan<- statResult) {
+			object, err := project.StatObject(ctx, pr.bucket, key)
+			result <- statResult{object: object, err: err}
+		}(pr.realKey+name, results[i])
+	}
+
+	// wait for all of them, but prefer the names in the configured order.
+	var object *uplink.Object
+	for _, result := range results {
+		res := <-result
+		switch {
+		case object != nil:
+		case res.err == nil:
+			object = res.object
+		case !errors.Is(res.err, uplink.ErrObjectNotFound):
+			err = errs.Combine(err, res.err)
+		}
+	}
+	if object == nil {
+		return "", WithAction(err, "stat readme")
+	}
+	if object.System.ContentLength > maxReadmeSize.Int64() {
+		return "", nil
+	}
+
+	download, err := project.DownloadObject(ctx, pr.bucket, object.Key, nil)
+	if err != nil {
+		return "", WithAction(err, "download readme")
+	}
+	defer func() {
+		if err := download.Close(); err != nil {
+			handler.log.With(zap.Error(err)).Warn("unable to close readme download")
+		}
+	}()
+
+	source, err := ioutil.ReadAll(io.LimitReader(download, maxReadmeSize.Int64()))
+	if err != nil {
+		return "", WithAction(err, "read readme")
+	}
+	return renderMarkdown(source), nil
+}
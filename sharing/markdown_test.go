@@ -0,0 +1,78 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	for _, test := range []struct {
+		source   string
+		contains []string
+		excludes []string
+	}{
+		{
+			source:   "# Dataset\n\nSee *below*.",
+			contains: []string{"<h1>Dataset</h1>", "<em>below</em>"},
+		},
+		{
+			source:   "hello <script>alert(1)</script> <b onclick=\"x()\">world</b>",
+			contains: []string{"hello", "world"},
+			excludes: []string{"<script", "onclick", "<b"},
+		},
+		{
+			source:   "[click](javascript:alert(1)) [data](data:text/html,x)",
+			contains: []string{"click", "data"},
+			excludes: []string{"javascript:", "data:text/html", "<a"},
+		},
+		{
+			source:   "[a](javascript&#58;alert(1)) [b](&#106;avascript:alert(1)) [c](java&Tab;script:alert(1)) [d](javascript&colon;alert(1))",
+			contains: []string{"a", "b", "c", "d"},
+			excludes: []string{"<a", "href"},
+		},
+		{
+			// double-encoded references stay harmless relative URLs.
+			source:   "[x](javascript&amp;#58;alert(1)) [y](&amp;#106;avascript:alert(1))",
+			contains: []string{"x", "y"},
+			excludes: []string{`href="javascript:`, `href="&#106;`},
+		},
+		{
+			source:   "[x]: javascript&amp;#58;alert(1)\n[z]: javascript&#58;alert(1)\n\n[y][x] [w][z]",
+			contains: []string{"y", "w", `href="javascript&amp;#58;alert(1)"`},
+			excludes: []string{`href="javascript:`},
+		},
+		{
+			source:   "![a](javascript&amp;#58;x) ![b](&amp;#106;avascript:x)",
+			excludes: []string{`src="javascript:`, `src="&#106;`},
+		},
+		{
+			source:   "![a](javascript&#58;x) ![b](&#106;avascript:x)",
+			excludes: []string{"<img", "src"},
+		},
+		{
+			source:   "[docs](https&#58;//storj.io/docs?a=1&amp;b=2)",
+			contains: []string{`href="https://storj.io/docs?a=1&amp;b=2"`},
+		},
+		{
+			source:   "[docs](https://storj.io/docs) [file](sub/file.csv)",
+			contains: []string{`href="https://storj.io/docs"`, `rel="nofollow noreferrer noopener"`, `target="_blank"`, `href="sub/file.csv"`},
+		},
+		{
+			source:   "![plot](img/plot.png) ![remote](https://example.com/a.png) ![bad](javascript:x)",
+			contains: []string{`src="img/plot.png?wrap=0"`, `src="https://example.com/a.png"`},
+			excludes: []string{"javascript:", `alt="bad"`},
+		},
+	} {
+		html := string(renderMarkdown([]byte(test.source)))
+		for _, s := range test.contains {
+			assert.Contains(t, html, s, test.source)
+		}
+		for _, s := range test.excludes {
+			assert.NotContains(t, html, s, test.source)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+
+	"storj.io/uplink"
+)
+
+// mediaExtensions are the media classes of extensions that browsers can play
+// or show, but which are missing from many mime type tables.
+var mediaExtensions = map[string]string{
+	".avif": "image", ".bmp": "image", ".gif": "image", ".ico": "image", ".jpeg": "image",
+	".jpg": "image", ".png": "image", ".svg": "image", ".webp": "image",
+	".m4v": "video", ".mkv": "video", ".mov": "video", ".mp4": "video", ".ogv": "video", ".webm": "video",
+	".flac": "audio", ".m4a": "audio", ".mp3": "audio", ".oga": "audio", ".ogg": "audio", ".opus": "audio", ".wav": "audio",
+	".pdf": "pdf",
+}
+
+// mediaClass returns how the object can be shown in a page: "image",
+// "video", "audio" or "pdf", or empty if it can't be.
+func mediaClass(o *uplink.Object) string {
+	// an explicit content type takes precedence over the extension.
+	if _, ok := metadataLookup(o.Custom, "Content-Type"); !ok {
+		if class, ok := mediaExtensions[strings.ToLower(path.Ext(o.Key))]; ok {
+			return class
+		}
+	}
+
+	mediaType, _, err := mime.ParseMediaType(objectContentType(o))
+	if err != nil {
+		return ""
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "image/"):
+		return "image"
+	case strings.HasPrefix(mediaType, "video/"):
+		return "video"
+	case strings.HasPrefix(mediaType, "audio/"):
+		return "audio"
+	case mediaType == "application/pdf":
+		return "pdf"
+	}
+	return ""
+}
+
+// rawURL returns the URL that serves the contents of the object with the
+// given key without wrapping it in a page.
+func (pr *parsedRequest) rawURL(key string) string {
+	if pr.rawRoot == "" {
+		return "?wrap=0"
+	}
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return pr.rawRoot + strings.Join(segments, "/")
+}
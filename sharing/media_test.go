@@ -0,0 +1,38 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"storj.io/uplink"
+)
+
+func TestMediaClass(t *testing.T) {
+	for _, test := range []struct {
+		key    string
+		custom uplink.CustomMetadata
+		class  string
+	}{
+		{key: "photo.JPG", class: "image"},
+		{key: "talk.mkv", class: "video"},
+		{key: "episode.mp3", class: "audio"},
+		{key: "paper.pdf", class: "pdf"},
+		{key: "notes.txt", class: ""},
+		{key: "recording", custom: uplink.CustomMetadata{"Content-Type": "video/mp4"}, class: "video"},
+		{key: "fake.png", custom: uplink.CustomMetadata{"Content-Type": "text/plain"}, class: ""},
+	} {
+		assert.Equal(t, test.class, mediaClass(&uplink.Object{Key: test.key, Custom: test.custom}), test.key)
+	}
+}
+
+func TestRawURL(t *testing.T) {
+	pr := &parsedRequest{rawRoot: "/raw/ACCESS/bucket/"}
+	assert.Equal(t, "/raw/ACCESS/bucket/a%20dir/clip%231.mp4", pr.rawURL("a dir/clip#1.mp4"))
+
+	hosted := &parsedRequest{}
+	assert.Equal(t, "?wrap=0", hosted.rawURL("clip.mp4"))
+}
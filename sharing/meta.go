@@ -0,0 +1,134 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"storj.io/common/memory"
+	"storj.io/uplink"
+)
+
+// previewImageWidth is the width of the images in link previews.
+const previewImageWidth = 1200
+
+// pageMeta is the Open Graph and Twitter Card metadata of a page, which chat
+// apps and social networks show as a preview of shared links.
+type pageMeta struct {
+	URL         string // the absolute URL of the page, without query
+	Type        string // og:type
+	Description string
+	Size        string // shown as a label in previews, if set
+
+	Image       string // absolute URL, if there is an image
+	ImageWidth  int    // zero if unknown
+	ImageHeight int    // zero if unknown
+
+	// Video is the absolute URL of a video to play in previews, if set.
+	Video       string
+	ContentType string
+
+	// OEmbedURL is the absolute URL of the oEmbed description of the page,
+	// if there is one.
+	OEmbedURL string
+}
+
+// Card returns the kind of Twitter Card for the page.
+func (meta *pageMeta) Card() string {
+	if meta.Image != "" {
+		return "summary_large_image"
+	}
+	return "summary"
+}
+
+// pageURL returns the absolute URL of the requested page, without query.
+// pages on our domains are linked through the primary URL base.
+func (handler *Handler) pageURL(r *http.Request) *url.URL {
+	if ours, err := isDomainOurs(r.Host, handler.urlBases); err == nil && ours {
+		u := *handler.urlBases[0]
+		u.Path = strings.TrimSuffix(u.Path, "/") + r.URL.Path
+		u.RawPath, u.RawQuery, u.Fragment = "", "", ""
+		return &u
+	}
+	u := &url.URL{Path: r.URL.Path}
+	u.Scheme = "http"
+	if r.TLS != nil || handler.redirectHTTPS {
+		u.Scheme = "https"
+	}
+	u.Host = r.Host
+	return u
+}
+
+// oembedURL returns the absolute URL of the oEmbed description of the shared
+// link at pageURL, or empty if there is none.
+func (handler *Handler) oembedURL(pageURL *url.URL) string {
+	if pageURL.Host != handler.urlBases[0].Host || !hasSharePrefix(pageURL.Path) {
+		return ""
+	}
+	u := *handler.urlBases[0]
+	u.Path = path.Join("/", u.Path, "oembed")
+	u.RawQuery = url.Values{"url": {pageURL.String()}, "format": {"json"}}.Encode()
+	return u.String()
+}
+
+// objectMeta returns the metadata of the wrapped page of o.
+func (handler *Handler) objectMeta(r *http.Request, o *uplink.Object) *pageMeta {
+	pageURL := handler.pageURL(r)
+	meta := &pageMeta{
+		URL:         pageURL.String(),
+		Type:        "website",
+		Size:        memory.Size(o.System.ContentLength).Base10String(),
+		Description: memory.Size(o.System.ContentLength).Base10String() + " file shared on Storj DCS",
+		ContentType: objectContentType(o),
+		OEmbedURL:   handler.oembedURL(pageURL),
+	}
+
+	rawURL := *pageURL
+	switch mediaClass(o) {
+	case "image":
+		rawURL.RawQuery = "wrap=0"
+		if handler.resizable(o) {
+			rawURL.RawQuery = url.Values{"wrap": {"0"}, "w": {strconv.Itoa(previewImageWidth)}}.Encode()
+		}
+		meta.Image = rawURL.String()
+		return meta
+	case "video":
+		rawURL.RawQuery = "wrap=0"
+		meta.Type = "video.other"
+		meta.Video = rawURL.String()
+	}
+
+	// everything else is previewed with the map of where its pieces are.
+	mapURL := *pageURL
+	mapURL.RawQuery = url.Values{
+		"map":   {"1"},
+		"width": {strconv.Itoa(previewImageWidth)},
+		"fmt":   {"png"},
+	}.Encode()
+	meta.Image = mapURL.String()
+	meta.ImageWidth, meta.ImageHeight = previewImageWidth, previewImageWidth/2
+	return meta
+}
+
+// prefixMeta returns the metadata of a prefix listing, using the first
+// image in it as its image.
+func (handler *Handler) prefixMeta(r *http.Request, images []string) *pageMeta {
+	pageURL := handler.pageURL(r)
+	meta := &pageMeta{
+		URL:         pageURL.String(),
+		Type:        "website",
+		Description: "Folder shared on Storj DCS",
+		OEmbedURL:   handler.oembedURL(pageURL),
+	}
+	if len(images) > 0 {
+		if imageURL, err := pageURL.Parse(images[0]); err == nil {
+			meta.Image = imageURL.String()
+		}
+	}
+	return meta
+}
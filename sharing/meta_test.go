@@ -0,0 +1,157 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"storj.io/common/memory"
+	"storj.io/common/testcontext"
+	"storj.io/dotworld/reference"
+	"storj.io/linksharing/objectmap"
+	"storj.io/uplink"
+)
+
+func newMetaTestHandler(t *testing.T) *Handler {
+	handler, err := NewHandler(&zap.Logger{}, &objectmap.IPDB{}, Config{
+		URLBases:  []string{"https://link.test", "http://alt.test"},
+		Templates: "../web",
+		Images:    ImageConfig{MaxSourceSize: memory.MiB},
+	})
+	require.NoError(t, err)
+	return handler
+}
+
+func TestPageURL(t *testing.T) {
+	handler := newMetaTestHandler(t)
+
+	r := httptest.NewRequest("GET", "http://alt.test/s/ACCESS/bucket/a%20b.png?wrap=1", nil)
+	assert.Equal(t, "https://link.test/s/ACCESS/bucket/a%20b.png", handler.pageURL(r).String())
+	assert.Equal(t, "https://link.test/oembed?format=json&url=https%3A%2F%2Flink.test%2Fs%2FACCESS%2Fbucket%2Fa%2520b.png",
+		handler.oembedURL(handler.pageURL(r)))
+
+	r = httptest.NewRequest("GET", "http://www.example.com/photos/cat.png", nil)
+	assert.Equal(t, "http://www.example.com/photos/cat.png", handler.pageURL(r).String())
+	assert.Empty(t, handler.oembedURL(handler.pageURL(r)))
+
+	r.TLS = &tls.ConnectionState{}
+	assert.Equal(t, "https://www.example.com/photos/cat.png", handler.pageURL(r).String())
+}
+
+func TestObjectMeta(t *testing.T) {
+	handler := newMetaTestHandler(t)
+	r := httptest.NewRequest("GET", "https://link.test/s/ACCESS/bucket/file", nil)
+	system := uplink.SystemMetadata{ContentLength: 1000}
+
+	meta := handler.objectMeta(r, &uplink.Object{Key: "cat.png", System: system})
+	assert.Equal(t, "https://link.test/s/ACCESS/bucket/file?w=1200&wrap=0", meta.Image)
+	assert.Equal(t, "summary_large_image", meta.Card())
+	assert.Equal(t, "1.00 KB", meta.Size)
+
+	meta = handler.objectMeta(r, &uplink.Object{Key: "talk.mp4", System: system})
+	assert.Equal(t, "video.other", meta.Type)
+	assert.Equal(t, "https://link.test/s/ACCESS/bucket/file?wrap=0", meta.Video)
+	assert.Equal(t, "https://link.test/s/ACCESS/bucket/file?fmt=png&map=1&width=1200", meta.Image)
+
+	meta = handler.objectMeta(r, &uplink.Object{Key: "data.bin", System: system})
+	assert.Equal(t, "website", meta.Type)
+	assert.Equal(t, 1200, meta.ImageWidth)
+	assert.Equal(t, 600, meta.ImageHeight)
+	assert.NotEmpty(t, meta.OEmbedURL)
+
+	r = httptest.NewRequest("GET", "https://link.test/s/ACCESS/bucket/pics/", nil)
+	meta = handler.prefixMeta(r, []string{"a%20b.png?wrap=0"})
+	assert.Equal(t, "https://link.test/s/ACCESS/bucket/pics/a%20b.png?wrap=0", meta.Image)
+	assert.Empty(t, handler.prefixMeta(r, nil).Image)
+}
+
+func TestPageMetaTemplate(t *testing.T) {
+	handler := newMetaTestHandler(t)
+	r := httptest.NewRequest("GET", "https://link.test/s/ACCESS/bucket/data.bin", nil)
+
+	w := httptest.NewRecorder()
+	handler.renderTemplate(w, "error.html", pageData{
+		Title: `"quoted" <title>`,
+		Meta:  handler.objectMeta(r, &uplink.Object{Key: "data.bin"}),
+	})
+	body := w.Body.String()
+	assert.Contains(t, body, `<meta property="og:title" content="&#34;quoted&#34; &lt;title&gt;">`)
+	assert.Contains(t, body, `<meta property="og:image" content="https://link.test/s/ACCESS/bucket/data.bin?fmt=png&amp;map=1&amp;width=1200">`)
+	assert.Contains(t, body, `type="application/json+oembed"`)
+
+	w = httptest.NewRecorder()
+	handler.renderTemplate(w, "error.html", pageData{Title: "Error"})
+	assert.NotContains(t, w.Body.String(), "og:title")
+}
+
+func TestServeOEmbedErrors(t *testing.T) {
+	ctx := testcontext.New(t)
+	handler := newMetaTestHandler(t)
+
+	for _, test := range []struct {
+		query  url.Values
+		status int
+	}{
+		{query: url.Values{"url": {"https://link.test/s/ACCESS/bucket/a"}, "format": {"xml"}}, status: http.StatusNotImplemented},
+		{query: url.Values{"url": {"https://elsewhere.test/s/ACCESS/bucket/a"}}, status: http.StatusNotFound},
+		{query: url.Values{"url": {"https://link.test/raw/ACCESS/bucket/a"}}, status: http.StatusNotFound},
+		{query: url.Values{"url": {"https://link.test/s/ACCESS"}}, status: http.StatusBadRequest},
+	} {
+		r := httptest.NewRequest("GET", "https://link.test/oembed?"+test.query.Encode(), nil).WithContext(ctx)
+		err := handler.serveOEmbed(ctx, httptest.NewRecorder(), r)
+		require.Error(t, err, test.query.Encode())
+		assert.Equal(t, test.status, GetStatus(err, 0), test.query.Encode())
+	}
+}
+
+func TestOEmbedResponse(t *testing.T) {
+	data, err := json.Marshal(oembedResponse{Type: "link", Version: "1.0", ProviderName: "Storj DCS", ProviderURL: "https://link.test"})
+	require.NoError(t, err)
+	assert.Equal(t, `{"type":"link","version":"1.0","provider_name":"Storj DCS","provider_url":"https://link.test"}`, string(data))
+}
+
+func TestFitWithin(t *testing.T) {
+	for _, test := range []struct {
+		width, height, maxWidth, maxHeight int
+		fitWidth, fitHeight                int
+	}{
+		{1000, 500, 0, 0, 1000, 500},
+		{1000, 500, 400, 0, 400, 200},
+		{1000, 500, 0, 100, 200, 100},
+		{1000, 500, 400, 100, 200, 100},
+		{100, 50, 400, 400, 100, 50},
+	} {
+		width, height := fitWithin(test.width, test.height, test.maxWidth, test.maxHeight)
+		assert.Equal(t, test.fitWidth, width)
+		assert.Equal(t, test.fitHeight, height)
+	}
+}
+
+func TestRasterizeMap(t *testing.T) {
+	img := rasterizeMap(reference.WorldMap(), 200, 100)
+	require.Equal(t, 200, img.Bounds().Dx())
+	require.Equal(t, 100, img.Bounds().Dy())
+
+	// some land is drawn.
+	var land bool
+	for i := 0; i < len(img.Pix) && !land; i += 4 {
+		land = img.Pix[i] != 0xff
+	}
+	assert.True(t, land)
+}
+
+func TestHasSharePrefix(t *testing.T) {
+	assert.True(t, hasSharePrefix("/s/ACCESS/bucket"))
+	assert.False(t, hasSharePrefix("/raw/ACCESS/bucket"))
+	assert.False(t, hasSharePrefix("/oembed"))
+}
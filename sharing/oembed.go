@@ -0,0 +1,217 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"image"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+
+	"storj.io/uplink"
+)
+
+// oembedHeaderSize is how much of an image is read to find its dimensions.
+const oembedHeaderSize = 256 << 10
+
+// oembedVideoWidth is the width of embedded videos if the consumer has no
+// preference.
+const oembedVideoWidth = 640
+
+// oembedResponse is an oEmbed response, see https://oembed.com/.
+type oembedResponse struct {
+	Type         string `json:"type"`
+	Version      string `json:"version"`
+	Title        string `json:"title,omitempty"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+
+	URL    string `json:"url,omitempty"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	HTML   string `json:"html,omitempty"`
+
+	ThumbnailURL    string `json:"thumbnail_url,omitempty"`
+	ThumbnailWidth  int    `json:"thumbnail_width,omitempty"`
+	ThumbnailHeight int    `json:"thumbnail_height,omitempty"`
+}
+
+// hasSharePrefix reports whether the URL path is of a wrapped shared link.
+func hasSharePrefix(urlPath string) bool {
+	return strings.HasPrefix(urlPath, "/s/")
+}
+
+// serveOEmbed describes the shared link in the url query parameter for
+// oEmbed consumers.
+func (handler *Handler) serveOEmbed(ctx context.Context, w http.ResponseWriter, r *http.Request) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	q := r.URL.Query()
+	if format := q.Get("format"); format != "" && format != "json" {
+		return WithStatus(errs.New("unsupported oembed format %q", format), http.StatusNotImplemented)
+	}
+
+	target, err := url.Parse(q.Get("url"))
+	if err != nil {
+		return WithStatus(errs.New("invalid oembed url: %w", err), http.StatusNotFound)
+	}
+	ours, err := isDomainOurs(target.Host, handler.urlBases)
+	if err != nil || !ours || !hasSharePrefix(target.Path) {
+		return WithStatus(errs.New("oembed url is not a shared link"), http.StatusNotFound)
+	}
+
+	pr := parsedRequest{wrapDefault: true}
+	if err := handler.parseStandardPath(ctx, r, strings.TrimPrefix(target.Path, "/s/"), &pr); err != nil {
+		return err
+	}
+
+	project, release, err := handler.openProject(ctx, pr.access)
+	if err != nil {
+		return WithStatus(WithAction(err, "open project"), http.StatusBadRequest)
+	}
+	defer release()
+
+	// oEmbed links point at our primary URL base.
+	pageURL := &url.URL{
+		Scheme: handler.urlBases[0].Scheme,
+		Host:   handler.urlBases[0].Host,
+		Path:   target.Path,
+	}
+	base := *handler.urlBases[0]
+	base.Path, base.RawQuery = "", ""
+
+	resp := oembedResponse{
+		Type:         "link",
+		Version:      "1.0",
+		Title:        pr.title,
+		ProviderName: "Storj DCS",
+		ProviderURL:  base.String(),
+	}
+	if pr.visibleKey != "" {
+		resp.Title = path.Base(pr.visibleKey)
+	}
+
+	if pr.realKey != "" && !strings.HasSuffix(pr.realKey, "/") {
+		o, err := project.StatObject(ctx, pr.bucket, pr.realKey)
+		switch {
+		case err == nil:
+			handler.describeObjectOEmbed(ctx, project, &pr, o, pageURL, q, &resp)
+		case !errors.Is(err, uplink.ErrObjectNotFound):
+			return WithAction(err, "stat object")
+		}
+		// anything else may be a prefix, which is just a link.
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(resp)
+}
+
+// describeObjectOEmbed fills in resp for the object o at pageURL, within the
+// maxwidth and maxheight query parameters.
+func (handler *Handler) describeObjectOEmbed(ctx context.Context, project *uplink.Project, pr *parsedRequest, o *uplink.Object, pageURL *url.URL, q url.Values, resp *oembedResponse) {
+	var err error
+	defer mon.Task()(&ctx)(&err)
+
+	maxWidth := queryIntLookup(q, "maxwidth", 0)
+	maxHeight := queryIntLookup(q, "maxheight", 0)
+
+	rawURL := *pageURL
+	rawURL.RawQuery = "wrap=0"
+
+	switch mediaClass(o) {
+	case "image":
+		var config image.Config
+		config, err = handler.imageConfig(ctx, project, pr, o)
+		if err != nil {
+			handler.log.Debug("unable to read image dimensions", zap.Error(err))
+			break
+		}
+		width, height := fitWithin(config.Width, config.Height, maxWidth, maxHeight)
+		if width != config.Width && handler.resizable(o) {
+			rawURL.RawQuery = url.Values{"wrap": {"0"}, "w": {strconv.Itoa(width)}}.Encode()
+		} else {
+			width, height = config.Width, config.Height
+		}
+		resp.Type = "photo"
+		resp.URL = rawURL.String()
+		resp.Width, resp.Height = width, height
+		return
+
+	case "video":
+		width := oembedVideoWidth
+		if maxWidth > 0 && maxWidth < width {
+			width = maxWidth
+		}
+		height := width * 9 / 16
+		if maxHeight > 0 && maxHeight < height {
+			width, height = maxHeight*16/9, maxHeight
+		}
+		resp.Type = "video"
+		resp.Width, resp.Height = width, height
+		resp.HTML = fmt.Sprintf(`<video src="%s" width="%d" height="%d" controls preload="metadata"></video>`,
+			template.HTMLEscapeString(rawURL.String()), width, height)
+	}
+
+	mapURL := *pageURL
+	mapURL.RawQuery = url.Values{
+		"map":   {"1"},
+		"width": {strconv.Itoa(previewImageWidth)},
+		"fmt":   {"png"},
+	}.Encode()
+	resp.ThumbnailURL = mapURL.String()
+	resp.ThumbnailWidth, resp.ThumbnailHeight = previewImageWidth, previewImageWidth/2
+}
+
+// imageConfig returns the dimensions of the image object o, reading as
+// little of it as possible.
+func (handler *Handler) imageConfig(ctx context.Context, project *uplink.Project, pr *parsedRequest, o *uplink.Object) (config image.Config, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	download, err := project.DownloadObject(ctx, pr.bucket, o.Key, &uplink.DownloadOptions{Length: oembedHeaderSize})
+	if err != nil {
+		return config, WithAction(err, "download image header")
+	}
+	defer func() {
+		if err := download.Close(); err != nil {
+			handler.log.With(zap.Error(err)).Warn("unable to close image header download")
+		}
+	}()
+
+	header, err := ioutil.ReadAll(download)
+	if err != nil {
+		return config, WithAction(err, "read image header")
+	}
+	config, _, err = image.DecodeConfig(bytes.NewReader(header))
+	return config, err
+}
+
+// fitWithin returns the dimensions of a width x height box scaled down to fit
+// within maxWidth x maxHeight, where zero means no limit.
+func fitWithin(width, height, maxWidth, maxHeight int) (int, int) {
+	if maxWidth > 0 && width > maxWidth {
+		width, height = maxWidth, height*maxWidth/width
+	}
+	if maxHeight > 0 && height > maxHeight {
+		width, height = width*maxHeight/height, maxHeight
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	return width, height
+}
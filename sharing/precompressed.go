@@ -0,0 +1,136 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"storj.io/uplink"
+)
+
+// contentCoding is a content coding we can serve, either from a
+// precompressed sibling object (key+suffix) or by compressing on the fly.
+type contentCoding struct {
+	coding string
+	suffix string
+}
+
+// contentCodings are the supported content codings, in order of preference
+// when the client accepts several of them equally.
+var contentCodings = []contentCoding{
+	{coding: "br", suffix: ".br"},
+	{coding: "gzip", suffix: ".gz"},
+}
+
+// acceptedContentCodings parses an Accept-Encoding header and returns the
+// supported content codings the client accepts, most preferred first.
+// codings with a quality of zero are excluded.
+func acceptedContentCodings(header string) []contentCoding {
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		if coding == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(param[len("q="):], 64)
+			if err != nil {
+				parsed = 0
+			}
+			q = parsed
+		}
+		qualities[coding] = q
+	}
+
+	var accepted []contentCoding
+	for _, enc := range contentCodings {
+		q, ok := qualities[enc.coding]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > 0 {
+			accepted = append(accepted, enc)
+		}
+	}
+
+	// a stable sort keeps our preference order among equal qualities.
+	sort.SliceStable(accepted, func(i, k int) bool {
+		qi, ok := qualities[accepted[i].coding]
+		if !ok {
+			qi = qualities["*"]
+		}
+		qk, ok := qualities[accepted[k].coding]
+		if !ok {
+			qk = qualities["*"]
+		}
+		return qi > qk
+	})
+	return accepted
+}
+
+// negotiatePrecompressed looks for a precompressed sibling of o that the
+// client accepts (e.g. key.br or key.gz) and returns it along with its
+// content coding. if there is none, o is returned with an empty coding.
+// siblings are looked up concurrently, so this costs at most one extra round
+// trip. since the response depends on Accept-Encoding, a Vary header is set
+// unless o already carries its own content encoding.
+func (handler *Handler) negotiatePrecompressed(ctx context.Context, w http.ResponseWriter, r *http.Request, bucket string, project *uplink.Project, o *uplink.Object) (_ *uplink.Object, coding string) {
+	defer mon.Task()(&ctx)(nil)
+
+	if _, ok := metadataLookup(o.Custom, "Content-Encoding"); ok {
+		return o, ""
+	}
+	addVary(w.Header(), "Accept-Encoding")
+
+	candidates := acceptedContentCodings(r.Header.Get("Accept-Encoding"))
+	if len(candidates) == 0 {
+		return o, ""
+	}
+
+	// lookups we don't wait for are canceled, and waited for before
+	// returning, since the project may be closed right after.
+	ctx, cancel := context.WithCancel(ctx)
+	var lookups sync.WaitGroup
+	defer lookups.Wait()
+	defer cancel()
+
+	siblings := make([]chan *uplink.Object, len(candidates))
+	for i, enc := range candidates {
+		siblings[i] = make(chan *uplink.Object, 1)
+		lookups.Add(1)
+		go func(ch chan *uplink.Object, key string) {
+			defer lookups.Done()
+			sibling, err := project.StatObject(ctx, bucket, key)
+			if err != nil {
+				if !errors.Is(err, uplink.ErrObjectNotFound) && !errors.Is(err, context.Canceled) {
+					handler.log.Debug("unable to stat precompressed object",
+						zap.String("key", key), zap.Error(err))
+				}
+				sibling = nil
+			}
+			ch <- sibling
+		}(siblings[i], o.Key+enc.suffix)
+	}
+
+	for i, ch := range siblings {
+		if sibling := <-ch; sibling != nil {
+			return sibling, candidates[i].coding
+		}
+	}
+	return o, ""
+}
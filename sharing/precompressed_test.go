@@ -0,0 +1,37 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcceptedContentCodings(t *testing.T) {
+	for _, test := range []struct {
+		header   string
+		expected []string
+	}{
+		{header: "", expected: nil},
+		{header: "identity", expected: nil},
+		{header: "gzip", expected: []string{"gzip"}},
+		{header: "br", expected: []string{"br"}},
+		{header: "gzip, deflate, br", expected: []string{"br", "gzip"}},
+		{header: "GZIP, BR", expected: []string{"br", "gzip"}},
+		{header: "br;q=0.5, gzip", expected: []string{"gzip", "br"}},
+		{header: "br;q=0, gzip", expected: []string{"gzip"}},
+		{header: "gzip;q=0.8, br;q=0.9", expected: []string{"br", "gzip"}},
+		{header: "*", expected: []string{"br", "gzip"}},
+		{header: "*;q=0.1, gzip", expected: []string{"gzip", "br"}},
+		{header: "*, br;q=0", expected: []string{"gzip"}},
+		{header: "br;q=bogus, gzip", expected: []string{"gzip"}},
+	} {
+		var codings []string
+		for _, enc := range acceptedContentCodings(test.header) {
+			codings = append(codings, enc.coding)
+		}
+		assert.Equal(t, test.expected, codings, test.header)
+	}
+}
@@ -6,7 +6,6 @@ package sharing
 import (
 	"context"
 	"errors"
-	"mime"
 	"net/http"
 	"path/filepath"
 	"strings"
@@ -16,33 +15,35 @@ import (
 
 	"storj.io/common/memory"
 	"storj.io/common/ranger/httpranger"
-	"storj.io/linksharing/objectranger"
 	"storj.io/uplink"
 )
 
 type parsedRequest struct {
-	access          *uplink.Access
-	bucket          string
-	realKey         string
-	visibleKey      string
-	title           string
-	root            breadcrumb
+	access     *uplink.Access
+	bucket     string
+	realKey    string
+	visibleKey string
+	title      string
+	root       breadcrumb
+	// rawRoot is the URL under which the objects of the bucket are served
+	// unwrapped, if there is one.
+	rawRoot         string
 	wrapDefault     bool
 	downloadDefault bool
+
+	// precompressed enables serving precompressed siblings of objects
+	// (key.br, key.gz) to clients that accept them.
+	precompressed bool
 }
 
 func (handler *Handler) present(ctx context.Context, w http.ResponseWriter, r *http.Request, pr *parsedRequest) (err error) {
 	defer mon.Task()(&ctx)(&err)
 
-	project, err := handler.uplink.OpenProject(ctx, pr.access)
+	project, release, err := handler.openProject(ctx, pr.access)
 	if err != nil {
 		return WithStatus(WithAction(err, "open project"), http.StatusBadRequest)
 	}
-	defer func() {
-		if err := project.Close(); err != nil {
-			handler.log.With(zap.Error(err)).Warn("unable to close project")
-		}
-	}()
+	defer release()
 
 	return handler.presentWithProject(ctx, w, r, pr, project)
 }
@@ -62,7 +63,7 @@ func (handler *Handler) presentWithProject(ctx context.Context, w http.ResponseW
 
 	if pr.realKey == "" || strings.HasSuffix(pr.realKey, "/") {
 		go func() {
-			obj, err := project.StatObject(ctx, pr.bucket, pr.realKey+"index.html")
+			obj, err := handler.statObject(ctx, project, pr, pr.realKey+"index.html")
 			indexResultCh <- statResult{obj: obj, err: err}
 		}()
 	} else {
@@ -72,7 +73,7 @@ func (handler *Handler) presentWithProject(ctx context.Context, w http.ResponseW
 	}
 
 	if pr.realKey != "" { // there are no objects with the empty key
-		o, err := project.StatObject(ctx, pr.bucket, pr.realKey)
+		o, err := handler.statObject(ctx, project, pr, pr.realKey)
 		if err == nil {
 			return handler.showObject(ctx, w, r, pr, project, o)
 		}
@@ -115,7 +116,7 @@ func (handler *Handler) presentWithProject(ctx context.Context, w http.ResponseW
 		return nil
 	}
 
-	return handler.servePrefix(ctx, w, project, pr)
+	return handler.servePrefix(ctx, w, r, project, pr)
 }
 
 func (handler *Handler) showObject(ctx context.Context, w http.ResponseWriter, r *http.Request, pr *parsedRequest, project *uplink.Project, o *uplink.Object) (err error) {
@@ -127,6 +128,13 @@ func (handler *Handler) showObject(ctx context.Context, w http.ResponseWriter, r
 		return handler.serveMap(ctx, w, pr, o, q)
 	}
 
+	// resizable images are transformed as requested. other objects,
+	// including images that can't be resized, ignore the parameters and are
+	// served as is, since hosted sites may use them for something else.
+	if resizeRequested(q) && !queryFlagLookup(q, "download", false) && handler.resizable(o) {
+		return handler.serveResized(ctx, w, r, project, pr, o)
+	}
+
 	// if someone provides the 'download' flag on or off, we do that, otherwise
 	// we do what the downloadDefault was (based on the URL scope).
 	download := queryFlagLookup(q, "download", pr.downloadDefault)
@@ -136,31 +144,60 @@ func (handler *Handler) showObject(ctx context.Context, w http.ResponseWriter, r
 	wrap := queryFlagLookup(q, "wrap",
 		!queryFlagLookup(q, "view", !pr.wrapDefault))
 
-	if download {
-		w.Header().Set("Content-Disposition", "attachment")
-	}
 	if download || !wrap {
-		contentType := mime.TypeByExtension(filepath.Ext(o.Key))
-		if contentType != "" {
-			w.Header().Set("Content-Type", contentType)
-		} else {
-			w.Header().Set("Content-Type", "application/octet-stream")
+		// served is the object whose contents we send. it differs from o
+		// when a precompressed sibling is served instead, in which case the
+		// headers describing the content (e.g. its type) still come from o.
+		served, coding := o, ""
+		if pr.precompressed {
+			served, coding = handler.negotiatePrecompressed(ctx, w, r, pr.bucket, project, o)
+		}
+
+		setObjectHeaders(w.Header(), o)
+		if coding != "" {
+			w.Header().Set("Content-Encoding", coding)
+		}
+		if download {
+			w.Header().Set("Content-Disposition", "attachment")
 		}
 
-		httpranger.ServeContent(ctx, w, r, o.Key, o.System.Created, objectranger.New(project, o, pr.bucket))
+		// httpranger.ServeContent evaluates If-None-Match and If-Range
+		// against this header, so setting it is all that's needed for
+		// conditional requests to get a 304 or a valid partial response.
+		w.Header().Set("ETag", objectETag(served))
+
+		body := handler.objectBody(ctx, project, pr, served)
+		httpranger.ServeContent(ctx, w, r, o.Key, served.System.Created, body)
 		return nil
 	}
 
 	var input struct {
-		Key  string
-		Size string
+		Key         string
+		Size        string
+		Preview     *objectPreview
+		Media       string
+		RawURL      string
+		ContentType string
+		QR          bool
 	}
 	input.Key = filepath.Base(o.Key)
 	input.Size = memory.Size(o.System.ContentLength).Base10String()
+	input.Media = mediaClass(o)
+	input.RawURL = pr.rawURL(o.Key)
+	input.ContentType = objectContentType(o)
+	// QR codes are served for standard requests only.
+	input.QR = pr.rawRoot != ""
+
+	input.Preview, err = handler.previewObject(ctx, project, pr, o)
+	if err != nil {
+		// the page is still useful without the preview.
+		handler.log.Debug("unable to preview object", zap.Error(err))
+	}
 
 	handler.renderTemplate(w, "single-object.html", pageData{
 		Data:  input,
 		Title: input.Key,
+		Meta:  handler.objectMeta(r, o),
 	})
 	return nil
 }
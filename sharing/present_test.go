@@ -11,6 +11,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 
+	"storj.io/common/memory"
 	"storj.io/common/testcontext"
 	"storj.io/linksharing/objectmap"
 	"storj.io/uplink"
@@ -53,3 +54,40 @@ func TestDownloadContentTypeHeader(t *testing.T) {
 	require.True(t, haveType)
 	require.Equal(t, "application/octet-stream", ctypes[0])
 }
+
+func TestShowObjectNotResizable(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	handler, err := NewHandler(&zap.Logger{}, &objectmap.IPDB{}, Config{
+		URLBases:  []string{"http://test.test"},
+		Templates: "../web",
+		Images:    ImageConfig{MaxSourceSize: memory.MiB},
+	})
+	require.NoError(t, err)
+	disabled, err := NewHandler(&zap.Logger{}, &objectmap.IPDB{}, Config{
+		URLBases:  []string{"http://test.test"},
+		Templates: "../web",
+	})
+	require.NoError(t, err)
+
+	// images that can't be resized are served as is.
+	for _, test := range []struct {
+		handler     *Handler
+		key         string
+		contentType string
+	}{
+		{handler: handler, key: "test.svg", contentType: "image/svg+xml"},
+		{handler: disabled, key: "test.jpg", contentType: "image/jpeg"},
+	} {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequestWithContext(ctx, "GET", "http://test.test/"+test.key+"?wrap=0&w=100", nil)
+		require.NoError(t, err)
+
+		err = test.handler.showObject(ctx, w, r, &parsedRequest{}, &uplink.Project{}, &uplink.Object{Key: test.key})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, w.Code, test.key)
+		require.Equal(t, test.contentType, w.Header().Get("Content-Type"), test.key)
+		require.NotEqual(t, resizedCacheControl, w.Header().Get("Cache-Control"), test.key)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"io/ioutil"
+	"mime"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+
+	"storj.io/common/memory"
+	"storj.io/uplink"
+)
+
+// defaultPreviewLength is how much of an object is previewed if it isn't
+// configured.
+const defaultPreviewLength = 64 * memory.KiB
+
+// PreviewConfig configures the previews of text objects in the wrapped
+// object page.
+type PreviewConfig struct {
+	// MaxSize is the size of the largest object that is previewed. zero
+	// disables previews.
+	MaxSize memory.Size
+	// Length is how much of an object is previewed.
+	Length memory.Size
+}
+
+// objectPreview is the preview of an object's contents.
+type objectPreview struct {
+	HTML      template.HTML
+	Markdown  bool
+	Truncated bool
+}
+
+// markdownObject reports whether the object with the given key and content
+// type is Markdown.
+func markdownObject(key, contentType string) bool {
+	switch strings.ToLower(path.Ext(key)) {
+	case ".md", ".markdown":
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "text/markdown"
+}
+
+// previewable reports whether the object may be previewed as text.
+func (handler *Handler) previewable(o *uplink.Object) bool {
+	if o.System.ContentLength == 0 || o.System.ContentLength > handler.preview.MaxSize.Int64() {
+		return false
+	}
+	if _, ok := metadataLookup(o.Custom, "Content-Encoding"); ok {
+		return false
+	}
+	contentType := objectContentType(o)
+	if markdownObject(o.Key, contentType) || syntaxFor(o.Key) != nil {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType != "application/wasm" && !strings.HasPrefix(mediaType, "image/") &&
+		compressibleContentType(contentType)
+}
+
+// previewText returns the text to preview from the beginning of an object,
+// with an incomplete last line dropped if it was truncated. ok is false if
+// it doesn't look like text.
+func previewText(data []byte, truncated bool) (text string, ok bool) {
+	if truncated {
+		if i := bytes.LastIndexByte(data, '\n'); i >= 0 {
+			data = data[:i+1]
+		} else {
+			// a single long line; only drop an incomplete rune.
+			for n := 0; n < utf8.UTFMax && len(data) > 0 && !utf8.Valid(data); n++ {
+				data = data[:len(data)-1]
+			}
+		}
+	}
+	if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+		return "", false
+	}
+	return string(data), true
+}
+
+// previewObject returns the preview of the object's contents, or nil if it
+// can't be previewed.
+func (handler *Handler) previewObject(ctx context.Context, project *uplink.Project, pr *parsedRequest, o *uplink.Object) (preview *objectPreview, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	if !handler.previewable(o) {
+		return nil, nil
+	}
+
+	length := handler.preview.Length.Int64()
+	if length <= 0 {
+		length = defaultPreviewLength.Int64()
+	}
+	truncated := o.System.ContentLength > length
+	if !truncated {
+		length = o.System.ContentLength
+	}
+
+	download, err := project.DownloadObject(ctx, pr.bucket, o.Key, &uplink.DownloadOptions{Length: length})
+	if err != nil {
+		return nil, WithAction(err, "download preview")
+	}
+	defer func() {
+		if err := download.Close(); err != nil {
+			handler.log.With(zap.Error(err)).Warn("unable to close preview download")
+		}
+	}()
+
+	data, err := ioutil.ReadAll(download)
+	if err != nil {
+		return nil, WithAction(err, "read preview")
+	}
+
+	text, ok := previewText(data, truncated)
+	if !ok {
+		return nil, nil
+	}
+
+	preview = &objectPreview{Truncated: truncated}
+	if markdownObject(o.Key, objectContentType(o)) {
+		preview.HTML = renderMarkdown([]byte(text))
+		preview.Markdown = true
+	} else {
+		preview.HTML = highlightSource(syntaxFor(o.Key), text)
+	}
+	return preview, nil
+}
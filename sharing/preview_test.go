@@ -0,0 +1,103 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"storj.io/common/memory"
+	"storj.io/uplink"
+)
+
+func TestPreviewable(t *testing.T) {
+	handler := &Handler{preview: PreviewConfig{MaxSize: memory.MiB}}
+
+	for _, test := range []struct {
+		key         string
+		size        int64
+		custom      uplink.CustomMetadata
+		previewable bool
+	}{
+		{key: "notes.txt", size: 100, previewable: true},
+		{key: "main.go", size: 100, previewable: true},
+		{key: "Dockerfile", size: 100, previewable: true},
+		{key: "README.md", size: 100, previewable: true},
+		{key: "server.log", size: 100, custom: uplink.CustomMetadata{"content-type": "text/plain"}, previewable: true},
+		{key: "photo.png", size: 100, previewable: false},
+		{key: "data.bin", size: 100, previewable: false},
+		{key: "logo.svg", size: 100, custom: uplink.CustomMetadata{"Content-Type": "image/svg+xml"}, previewable: true},
+		{key: "app.js", size: 100, custom: uplink.CustomMetadata{"Content-Encoding": "gzip"}, previewable: false},
+		{key: "huge.txt", size: 2 * memory.MiB.Int64(), previewable: false},
+		{key: "empty.txt", size: 0, previewable: false},
+	} {
+		object := &uplink.Object{
+			Key:    test.key,
+			System: uplink.SystemMetadata{ContentLength: test.size},
+			Custom: test.custom,
+		}
+		assert.Equal(t, test.previewable, handler.previewable(object), test.key)
+	}
+
+	disabled := &Handler{}
+	assert.False(t, disabled.previewable(&uplink.Object{Key: "notes.txt", System: uplink.SystemMetadata{ContentLength: 100}}))
+}
+
+func TestPreviewText(t *testing.T) {
+	text, ok := previewText([]byte("first\nsecond\nthi"), true)
+	assert.True(t, ok)
+	assert.Equal(t, "first\nsecond\n", text)
+
+	text, ok = previewText([]byte("first\nsecond\nthi"), false)
+	assert.True(t, ok)
+	assert.Equal(t, "first\nsecond\nthi", text)
+
+	// a long line cut in the middle of a rune.
+	text, ok = previewText([]byte("héllo wörld")[:9], true)
+	assert.True(t, ok)
+	assert.Equal(t, "héllo w", text)
+
+	_, ok = previewText([]byte("PK\x03\x04\x00\x00binary"), false)
+	assert.False(t, ok)
+
+	_, ok = previewText([]byte{0xff, 0xfe, 'a', '\n'}, false)
+	assert.False(t, ok)
+}
+
+func TestHighlightSource(t *testing.T) {
+	for _, test := range []struct {
+		key      string
+		source   string
+		expected string
+	}{
+		{
+			key:      "main.go",
+			source:   "// Package main.\nfunc main() { return \"<b>\" + 42 }",
+			expected: `<span class="hl-c">// Package main.</span>` + "\n" + `<span class="hl-k">func</span> main() { <span class="hl-k">return</span> <span class="hl-s">&#34;&lt;b&gt;&#34;</span> + <span class="hl-n">42</span> }`,
+		},
+		{
+			key:      "config.yaml",
+			source:   "# comment\nenabled: true # inline\nname: 'x'",
+			expected: `<span class="hl-c"># comment</span>` + "\n" + `enabled: <span class="hl-k">true</span> <span class="hl-c"># inline</span>` + "\n" + `name: <span class="hl-s">&#39;x&#39;</span>`,
+		},
+		{
+			key:      "page.html",
+			source:   "<!-- note --><a href=\"x\">",
+			expected: `<span class="hl-c">&lt;!-- note --&gt;</span>&lt;a href=<span class="hl-s">&#34;x&#34;</span>&gt;`,
+		},
+		{
+			key:      "notes.txt",
+			source:   "<script>alert(1)</script> // not a comment",
+			expected: "&lt;script&gt;alert(1)&lt;/script&gt; // not a comment",
+		},
+		{
+			key:      "unterminated.js",
+			source:   "x = \"open\ny = 1 /* open",
+			expected: `x = <span class="hl-s">&#34;open</span>` + "\n" + `y = <span class="hl-n">1</span> <span class="hl-c">/* open</span>`,
+		},
+	} {
+		assert.Equal(t, test.expected, string(highlightSource(syntaxFor(test.key), test.source)), test.key)
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+
+	"storj.io/uplink"
+)
+
+// ProjectPoolConfig configures the reuse of open projects across requests
+// with the same access grant.
+type ProjectPoolConfig struct {
+	// Capacity is the number of projects that are kept open. zero disables
+	// the pool, which opens a project for each request.
+	Capacity int
+	// TTL is how long after it's opened a project is reused.
+	TTL time.Duration
+}
+
+// pooledProject is an open project in a projectPool.
+type pooledProject struct {
+	key     [sha256.Size]byte
+	project *uplink.Project
+	opened  time.Time
+
+	element *list.Element // in the pool, nil once evicted
+	users   int           // requests using the project
+}
+
+// projectPool keeps projects open for reuse by later requests with the same
+// access grant. projects are closed once evicted and no longer in use.
+type projectPool struct {
+	log    *zap.Logger
+	uplink *uplink.Config
+	config ProjectPoolConfig
+
+	mu       sync.Mutex
+	projects map[[sha256.Size]byte]*pooledProject
+	order    *list.List // of *pooledProject, most recently used first
+}
+
+func newProjectPool(log *zap.Logger, uplinkConfig *uplink.Config, config ProjectPoolConfig) *projectPool {
+	return &projectPool{
+		log:      log,
+		uplink:   uplinkConfig,
+		config:   config,
+		projects: make(map[[sha256.Size]byte]*pooledProject),
+		order:    list.New(),
+	}
+}
+
+// Get returns an open project for access, and a function to call once the
+// project isn't used anymore.
+func (pool *projectPool) Get(ctx context.Context, access *uplink.Access) (_ *uplink.Project, release func(), err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	serialized, err := access.Serialize()
+	if err != nil {
+		return nil, nil, err
+	}
+	key := sha256.Sum256([]byte(serialized))
+
+	if pooled := pool.acquire(key); pooled != nil {
+		mon.Event("project_pool_hit")
+		return pooled.project, func() { pool.release(pooled) }, nil
+	}
+	mon.Event("project_pool_miss")
+
+	project, err := pool.uplink.OpenProject(ctx, access)
+	if err != nil {
+		return nil, nil, err
+	}
+	pooled := pool.add(&pooledProject{key: key, project: project, opened: time.Now(), users: 1})
+	return pooled.project, func() { pool.release(pooled) }, nil
+}
+
+// acquire returns the pooled project of key for use, if there is one.
+func (pool *projectPool) acquire(key [sha256.Size]byte) *pooledProject {
+	pool.mu.Lock()
+	pooled, ok := pool.projects[key]
+	if !ok {
+		pool.mu.Unlock()
+		return nil
+	}
+	if time.Since(pooled.opened) > pool.config.TTL {
+		mon.Event("project_pool_expired")
+		closing := pool.evict(pooled)
+		pool.mu.Unlock()
+
+		pool.close(closing...)
+		return nil
+	}
+	pool.order.MoveToFront(pooled.element)
+	pooled.users++
+	pool.mu.Unlock()
+
+	return pooled
+}
+
+// add adds the newly opened project to the pool, unless a concurrent request
+// added one for the same access first, in which case that one is returned
+// instead.
+func (pool *projectPool) add(pooled *pooledProject) *pooledProject {
+	pool.mu.Lock()
+	if existing, ok := pool.projects[pooled.key]; ok {
+		existing.users++
+		pool.order.MoveToFront(existing.element)
+		pool.mu.Unlock()
+
+		pool.close(pooled.project)
+		return existing
+	}
+
+	pooled.element = pool.order.PushFront(pooled)
+	pool.projects[pooled.key] = pooled
+	var closing []*uplink.Project
+	for pool.order.Len() > pool.config.Capacity {
+		mon.Event("project_pool_evicted")
+		closing = append(closing, pool.evict(pool.order.Back().Value.(*pooledProject))...)
+	}
+	mon.IntVal("project_pool_size").Observe(int64(pool.order.Len()))
+	pool.mu.Unlock()
+
+	pool.close(closing...)
+	return pooled
+}
+
+// release marks a use of the pooled project as finished, closing it if it
+// was evicted in the meantime.
+func (pool *projectPool) release(pooled *pooledProject) {
+	pool.mu.Lock()
+	pooled.users--
+	closing := pooled.users == 0 && pooled.element == nil
+	pool.mu.Unlock()
+
+	if closing {
+		pool.close(pooled.project)
+	}
+}
+
+// evict removes the pooled project from the pool, and returns it to be
+// closed unless it's in use. pool.mu must be held, and closing may wait for
+// the network, so it's done without it.
+func (pool *projectPool) evict(pooled *pooledProject) []*uplink.Project {
+	delete(pool.projects, pooled.key)
+	pool.order.Remove(pooled.element)
+	pooled.element = nil
+	if pooled.users > 0 {
+		return nil
+	}
+	return []*uplink.Project{pooled.project}
+}
+
+func (pool *projectPool) close(projects ...*uplink.Project) {
+	for _, project := range projects {
+		if err := project.Close(); err != nil {
+			pool.log.With(zap.Error(err)).Warn("unable to close project")
+		}
+	}
+}
+
+// Close closes all projects in the pool that aren't in use. the ones in use
+// are closed once they are released.
+func (pool *projectPool) Close() error {
+	pool.mu.Lock()
+	var closing []*uplink.Project
+	for _, pooled := range pool.projects {
+		closing = append(closing, pool.evict(pooled)...)
+	}
+	pool.mu.Unlock()
+
+	var group errs.Group
+	for _, project := range closing {
+		group.Add(project.Close())
+	}
+	return group.Err()
+}
+
+// openProject opens a project for access, from the pool if it's enabled. the
+// returned function must be called once the project isn't used anymore.
+func (handler *Handler) openProject(ctx context.Context, access *uplink.Access) (_ *uplink.Project, release func(), err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	if handler.projects != nil {
+		return handler.projects.Get(ctx, access)
+	}
+
+	project, err := handler.uplink.OpenProject(ctx, access)
+	if err != nil {
+		return nil, nil, err
+	}
+	return project, func() {
+		if err := project.Close(); err != nil {
+			handler.log.With(zap.Error(err)).Warn("unable to close project")
+		}
+	}, nil
+}
@@ -0,0 +1,77 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/grant"
+	"storj.io/common/macaroon"
+	"storj.io/common/testcontext"
+	"storj.io/common/testrand"
+	"storj.io/uplink"
+)
+
+func testAccess(t *testing.T) *uplink.Access {
+	apiKey, err := macaroon.NewAPIKey(testrand.BytesInt(32))
+	require.NoError(t, err)
+
+	serialized, err := (&grant.Access{
+		SatelliteAddress: testrand.NodeID().String() + "@127.0.0.1:7777",
+		APIKey:           apiKey,
+		EncAccess:        grant.NewEncryptionAccess(),
+	}).Serialize()
+	require.NoError(t, err)
+
+	access, err := uplink.ParseAccess(serialized)
+	require.NoError(t, err)
+	return access
+}
+
+func TestProjectPool(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	pool := newProjectPool(zaptest.NewLogger(t), &uplink.Config{}, ProjectPoolConfig{
+		Capacity: 2,
+		TTL:      time.Hour,
+	})
+	defer ctx.Check(pool.Close)
+
+	a, b, c := testAccess(t), testAccess(t), testAccess(t)
+
+	first, release, err := pool.Get(ctx, a)
+	require.NoError(t, err)
+	release()
+
+	second, release, err := pool.Get(ctx, a)
+	require.NoError(t, err)
+	assert.Same(t, first, second, "the project is reused")
+
+	// a is in use while b and c evict it.
+	for _, access := range []*uplink.Access{b, c} {
+		_, releaseOther, err := pool.Get(ctx, access)
+		require.NoError(t, err)
+		releaseOther()
+	}
+	assert.Len(t, pool.projects, 2)
+	release()
+
+	third, release, err := pool.Get(ctx, a)
+	require.NoError(t, err)
+	release()
+	assert.NotSame(t, first, third, "evicted projects aren't reused")
+
+	// expired projects aren't reused either.
+	pool.config.TTL = 0
+	fourth, release, err := pool.Get(ctx, a)
+	require.NoError(t, err)
+	release()
+	assert.NotSame(t, third, fourth)
+}
@@ -0,0 +1,107 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+	"github.com/zeebo/errs"
+)
+
+const (
+	// defaultQRSize is the width and height in pixels of QR codes if no size
+	// is requested.
+	defaultQRSize = 256
+	// maxQRSize is the largest QR code size that can be requested.
+	maxQRSize = 2048
+	// qrCacheControl is the Cache-Control header of QR codes, which only
+	// depend on the URL.
+	qrCacheControl = "public, max-age=604800"
+)
+
+// qrRecoveryLevels are the error correction levels of QR codes, by the
+// values of the ec query parameter.
+var qrRecoveryLevels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+// serveQR serves a QR code of the canonical link of the requested URL, as a
+// PNG, or as an SVG with ?fmt=svg. its size in pixels and error correction
+// level (L, M, Q or H) are set with the size and ec query parameters.
+func (handler *Handler) serveQR(ctx context.Context, w http.ResponseWriter, r *http.Request) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	q := r.URL.Query()
+
+	size := queryIntLookup(q, "size", defaultQRSize)
+	if size <= 0 || size > maxQRSize {
+		return WithStatus(errs.New("invalid qr size %d", size), http.StatusBadRequest)
+	}
+
+	ec := strings.ToUpper(q.Get("ec"))
+	if ec == "" {
+		ec = "M"
+	}
+	level, ok := qrRecoveryLevels[ec]
+	if !ok {
+		return WithStatus(errs.New("invalid qr error correction level %q", ec), http.StatusBadRequest)
+	}
+
+	code, err := qrcode.New(handler.pageURL(r).String(), level)
+	if err != nil {
+		return WithStatus(WithAction(err, "qr encode"), http.StatusBadRequest)
+	}
+
+	var data []byte
+	switch format := q.Get("fmt"); format {
+	case "", "png":
+		data, err = code.PNG(size)
+		if err != nil {
+			return WithAction(err, "qr png encode")
+		}
+		w.Header().Set("Content-Type", "image/png")
+	case "svg":
+		data = qrSVG(code.Bitmap(), size)
+		w.Header().Set("Content-Type", "image/svg+xml")
+	default:
+		return WithStatus(errs.New("invalid qr format %q", format), http.StatusBadRequest)
+	}
+
+	w.Header().Set("Cache-Control", qrCacheControl)
+	w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+	_, err = w.Write(data)
+	return err
+}
+
+// qrSVG draws the modules of a QR code as an SVG of the given size. dark
+// modules are merged into horizontal runs to keep the document small.
+func qrSVG(bitmap [][]bool, size int) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<?xml version="1.0" encoding="UTF-8" standalone="no"?>`)
+	fmt.Fprintf(&buf, `<svg width="%[1]d" height="%[1]d" viewBox="0 0 %[2]d %[2]d" shape-rendering="crispEdges" xmlns="http://www.w3.org/2000/svg">`,
+		size, len(bitmap))
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="#fff"/><path fill="#000" d="`)
+	for y, row := range bitmap {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", start, y, x-start, x-start)
+		}
+	}
+	fmt.Fprintf(&buf, `"/></svg>`)
+	return buf.Bytes()
+}
@@ -0,0 +1,64 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/skip2/go-qrcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/linksharing/objectmap"
+)
+
+func TestServeQR(t *testing.T) {
+	handler, err := NewHandler(zaptest.NewLogger(t), &objectmap.IPDB{}, Config{
+		URLBases:  []string{"https://link.test/base", "http://alt.test"},
+		Templates: "../web",
+	})
+	require.NoError(t, err)
+
+	serve := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		return w
+	}
+
+	w := serve("http://alt.test/s/ACCESS/bucket/data.csv?qr=1&size=300")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.Equal(t, qrCacheControl, w.Header().Get("Cache-Control"))
+	img, err := png.Decode(w.Body)
+	require.NoError(t, err)
+	assert.Equal(t, 300, img.Bounds().Dx())
+
+	// the code encodes the link through the primary URL base, whichever
+	// base and query it was requested with.
+	code, err := qrcode.New("https://link.test/base/raw/ACCESS/bucket/a%20b.csv", qrcode.Highest)
+	require.NoError(t, err)
+	w = serve("http://alt.test/raw/ACCESS/bucket/a%20b.csv?qr=1&fmt=svg&ec=h&size=500")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+	assert.Equal(t, string(qrSVG(code.Bitmap(), 500)), w.Body.String())
+
+	for _, query := range []string{"size=0", "size=5000", "ec=X", "fmt=gif"} {
+		w = serve("http://alt.test/s/ACCESS/bucket/data.csv?qr=1&" + query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestQRSVG(t *testing.T) {
+	svg := string(qrSVG([][]bool{
+		{true, true, false},
+		{false, true, true},
+		{true, false, true},
+	}, 90))
+	assert.Contains(t, svg, `width="90" height="90" viewBox="0 0 3 3"`)
+	assert.Contains(t, svg, `d="M0 0h2v1h-2zM1 1h2v1h-2zM0 2h1v1h-1zM2 2h1v1h-1z"`)
+}
@@ -0,0 +1,333 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/draw"
+	_ "image/gif" // register the GIF decoder for resizing.
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nfnt/resize"
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+
+	"storj.io/common/memory"
+	"storj.io/uplink"
+)
+
+// resizedCacheControl is the Cache-Control header of resized images. they
+// are expensive to make, and conditional requests are answered from their
+// ETag without downloading the object again.
+const resizedCacheControl = "public, max-age=2592000"
+
+// resizedJPEGQuality is the quality of resized images encoded as JPEG.
+const resizedJPEGQuality = 85
+
+// maxImageHeaderSize is the most that is read of an image to find its
+// dimensions, which leaves room for metadata before them.
+const maxImageHeaderSize = 1 << 20
+
+// ImageConfig configures the resizing of image objects with the w, h, fit and
+// fmt query parameters.
+type ImageConfig struct {
+	// MaxSourceSize is the size of the largest object that is resized. zero
+	// disables resizing.
+	MaxSourceSize memory.Size
+	// MaxSourcePixels is the number of pixels of the largest image that is
+	// decoded. zero means no limit.
+	MaxSourcePixels int
+	// MaxDimension is the largest width or height that can be requested.
+	// zero means no limit.
+	MaxDimension int
+	// MaxConcurrent is the number of images that are resized at the same
+	// time. other requests wait for their turn. zero means no limit.
+	MaxConcurrent int
+}
+
+// resizeRequest is a requested transformation of an image.
+type resizeRequest struct {
+	width  int    // zero to derive it from the height
+	height int    // zero to derive it from the width
+	fit    string // one of "contain", "cover" or "fill"
+	format string // one of "jpeg" or "png", empty for the source format
+}
+
+// galleryThumbnailQuery is the query of the thumbnails in gallery views.
+const galleryThumbnailQuery = "wrap=0&w=400&h=400&fit=cover"
+
+// resizable reports whether the object is an image that can be resized.
+func (handler *Handler) resizable(o *uplink.Object) bool {
+	if handler.images.MaxSourceSize <= 0 || o.System.ContentLength > handler.images.MaxSourceSize.Int64() {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(objectContentType(o))
+	switch mediaType {
+	case "image/jpeg", "image/png", "image/gif":
+		return true
+	}
+	return false
+}
+
+// resizeRequested reports whether the query asks for a resized image.
+func resizeRequested(q url.Values) bool {
+	for _, param := range []string{"w", "h", "fit", "fmt"} {
+		if _, ok := q[param]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// parseResizeRequest parses the w, h, fit and fmt query parameters.
+func parseResizeRequest(q url.Values, maxDimension int) (req resizeRequest, err error) {
+	dimension := func(param string) (int, error) {
+		value := q.Get(param)
+		if value == "" {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return 0, WithStatus(errs.New("invalid %s %q", param, value), http.StatusBadRequest)
+		}
+		if maxDimension > 0 && n > maxDimension {
+			return 0, WithStatus(errs.New("%s %d is larger than %d", param, n, maxDimension), http.StatusBadRequest)
+		}
+		return n, nil
+	}
+	if req.width, err = dimension("w"); err != nil {
+		return req, err
+	}
+	if req.height, err = dimension("h"); err != nil {
+		return req, err
+	}
+
+	switch req.fit = q.Get("fit"); req.fit {
+	case "":
+		req.fit = "contain"
+	case "contain", "cover", "fill":
+	default:
+		return req, WithStatus(errs.New("invalid fit %q", req.fit), http.StatusBadRequest)
+	}
+	if req.fit != "contain" && (req.width == 0 || req.height == 0) {
+		return req, WithStatus(errs.New("fit %q needs both w and h", req.fit), http.StatusBadRequest)
+	}
+
+	switch req.format = q.Get("fmt"); req.format {
+	case "", "png", "jpeg":
+	case "jpg":
+		req.format = "jpeg"
+	default:
+		return req, WithStatus(errs.New("invalid fmt %q", req.format), http.StatusBadRequest)
+	}
+	return req, nil
+}
+
+// etag returns the ETag of the image made from o by req.
+func (req resizeRequest) etag(o *uplink.Object) string {
+	h := sha256.New()
+	_, _ = io.WriteString(h, objectETag(o))
+	_, _ = io.WriteString(h, strconv.Itoa(req.width)+"x"+strconv.Itoa(req.height)+"/"+req.fit+"/"+req.format)
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// size returns the dimensions of the resized image and of the area it's
+// scaled to before cropping, for a source image of the given size. images
+// are never enlarged.
+func (req resizeRequest) size(src image.Point) (out, scaled image.Point) {
+	w, h := float64(src.X), float64(src.Y)
+	scaleX, scaleY := float64(req.width)/w, float64(req.height)/h
+	switch {
+	case req.width == 0 && req.height == 0:
+		scaleX, scaleY = 1, 1
+	case req.width == 0:
+		scaleX = scaleY
+	case req.height == 0:
+		scaleY = scaleX
+	}
+
+	switch req.fit {
+	case "fill":
+		scaleX, scaleY = minFloat(scaleX, 1), minFloat(scaleY, 1)
+	case "cover":
+		scale := minFloat(maxFloat(scaleX, scaleY), 1)
+		scaleX, scaleY = scale, scale
+	default:
+		scale := minFloat(minFloat(scaleX, scaleY), 1)
+		scaleX, scaleY = scale, scale
+	}
+
+	scaled = image.Pt(maxInt(int(w*scaleX+0.5), 1), maxInt(int(h*scaleY+0.5), 1))
+	out = scaled
+	if req.fit == "cover" {
+		if req.width < out.X {
+			out.X = req.width
+		}
+		if req.height < out.Y {
+			out.Y = req.height
+		}
+	}
+	return out, scaled
+}
+
+// resizeImage transforms img as requested.
+func resizeImage(img image.Image, req resizeRequest) image.Image {
+	out, scaled := req.size(img.Bounds().Size())
+	if scaled != img.Bounds().Size() {
+		img = resize.Resize(uint(scaled.X), uint(scaled.Y), img, resize.Lanczos3)
+	}
+	if out == scaled {
+		return img
+	}
+
+	// crop the center of the scaled image.
+	bounds := img.Bounds()
+	offset := bounds.Min.Add(scaled.Sub(out).Div(2))
+	cropped := image.NewRGBA(image.Rectangle{Max: out})
+	draw.Draw(cropped, cropped.Bounds(), img, offset, draw.Src)
+	return cropped
+}
+
+// serveResized serves the image object o transformed as requested by the
+// query.
+func (handler *Handler) serveResized(ctx context.Context, w http.ResponseWriter, r *http.Request, project *uplink.Project, pr *parsedRequest, o *uplink.Object) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	if handler.images.MaxSourceSize <= 0 {
+		return WithStatus(errs.New("image resizing is disabled"), http.StatusForbidden)
+	}
+	if o.System.ContentLength > handler.images.MaxSourceSize.Int64() {
+		return WithStatus(errs.New("image is too large to resize"), http.StatusRequestEntityTooLarge)
+	}
+
+	req, err := parseResizeRequest(r.URL.Query(), handler.images.MaxDimension)
+	if err != nil {
+		return err
+	}
+
+	etag := req.etag(o)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", resizedCacheControl)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	if handler.resizes != nil {
+		select {
+		case handler.resizes <- struct{}{}:
+			defer func() { <-handler.resizes }()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	download, err := project.DownloadObject(ctx, pr.bucket, o.Key, nil)
+	if err != nil {
+		return WithAction(err, "download image")
+	}
+	defer func() {
+		if err := download.Close(); err != nil {
+			handler.log.With(zap.Error(err)).Warn("unable to close image download")
+		}
+	}()
+
+	source, format, err := readImage(download, handler.images.MaxSourceSize.Int64(), handler.images.MaxSourcePixels)
+	if err != nil {
+		return err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(source))
+	if err != nil {
+		return WithStatus(errs.New("unable to decode image: %w", err), http.StatusBadRequest)
+	}
+	img = resizeImage(img, req)
+
+	if req.format == "" {
+		req.format = "png"
+		if format == "jpeg" {
+			req.format = "jpeg"
+		}
+	}
+
+	var buf bytes.Buffer
+	switch req.format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: resizedJPEGQuality})
+	default:
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return WithAction(err, "encode image")
+	}
+
+	w.Header().Set("Content-Type", "image/"+req.format)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(buf.Bytes()))
+	return nil
+}
+
+// readImage reads an image of at most maxSize bytes from r. its dimensions
+// are decoded from the first maxImageHeaderSize bytes and checked against
+// maxPixels, if positive, before the rest of it is read.
+func readImage(r io.Reader, maxSize int64, maxPixels int) (source []byte, format string, err error) {
+	var header bytes.Buffer
+	config, format, err := image.DecodeConfig(io.TeeReader(io.LimitReader(r, maxImageHeaderSize), &header))
+	if err != nil {
+		return nil, "", WithStatus(errs.New("unsupported image: %w", err), http.StatusBadRequest)
+	}
+	if maxPixels > 0 && config.Width*config.Height > maxPixels {
+		return nil, "", WithStatus(errs.New("image has too many pixels to resize"), http.StatusRequestEntityTooLarge)
+	}
+
+	source, err = ioutil.ReadAll(io.LimitReader(io.MultiReader(&header, r), maxSize))
+	if err != nil {
+		return nil, "", WithAction(err, "read image")
+	}
+	return source, format, nil
+}
+
+// etagMatches reports whether an If-None-Match header matches etag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
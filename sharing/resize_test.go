@@ -0,0 +1,152 @@
+// Copyright (C) 2021 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package sharing
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/memory"
+	"storj.io/uplink"
+)
+
+func TestParseResizeRequest(t *testing.T) {
+	for _, test := range []struct {
+		query string
+		req   resizeRequest
+		err   bool
+	}{
+		{query: "w=100", req: resizeRequest{width: 100, fit: "contain"}},
+		{query: "h=50&fmt=jpg", req: resizeRequest{height: 50, fit: "contain", format: "jpeg"}},
+		{query: "w=100&h=100&fit=cover&fmt=png", req: resizeRequest{width: 100, height: 100, fit: "cover", format: "png"}},
+		{query: "fmt=jpeg", req: resizeRequest{fit: "contain", format: "jpeg"}},
+		{query: "w=0", err: true},
+		{query: "w=abc", err: true},
+		{query: "w=5000", err: true},
+		{query: "w=100&fit=cover", err: true},
+		{query: "w=100&h=100&fit=stretch", err: true},
+		{query: "fmt=webp", err: true},
+	} {
+		q, err := url.ParseQuery(test.query)
+		require.NoError(t, err)
+		require.True(t, resizeRequested(q))
+
+		req, err := parseResizeRequest(q, 4096)
+		if test.err {
+			require.Error(t, err, test.query)
+			require.Equal(t, http.StatusBadRequest, GetStatus(err, 0), test.query)
+			continue
+		}
+		require.NoError(t, err, test.query)
+		assert.Equal(t, test.req, req, test.query)
+	}
+
+	assert.False(t, resizeRequested(url.Values{"wrap": {"0"}}))
+}
+
+func TestResizeRequestSize(t *testing.T) {
+	src := image.Pt(800, 400)
+	for _, test := range []struct {
+		req         resizeRequest
+		out, scaled image.Point
+	}{
+		{req: resizeRequest{width: 200, fit: "contain"}, out: image.Pt(200, 100), scaled: image.Pt(200, 100)},
+		{req: resizeRequest{height: 200, fit: "contain"}, out: image.Pt(400, 200), scaled: image.Pt(400, 200)},
+		{req: resizeRequest{width: 200, height: 200, fit: "contain"}, out: image.Pt(200, 100), scaled: image.Pt(200, 100)},
+		{req: resizeRequest{width: 200, height: 200, fit: "cover"}, out: image.Pt(200, 200), scaled: image.Pt(400, 200)},
+		{req: resizeRequest{width: 200, height: 200, fit: "fill"}, out: image.Pt(200, 200), scaled: image.Pt(200, 200)},
+		// images are never enlarged.
+		{req: resizeRequest{width: 1600, fit: "contain"}, out: src, scaled: src},
+		{req: resizeRequest{width: 1000, height: 1000, fit: "cover"}, out: image.Pt(800, 400), scaled: src},
+		{req: resizeRequest{fit: "contain", format: "png"}, out: src, scaled: src},
+	} {
+		out, scaled := test.req.size(src)
+		assert.Equal(t, test.out, out, "%+v", test.req)
+		assert.Equal(t, test.scaled, scaled, "%+v", test.req)
+	}
+}
+
+func TestResizeImage(t *testing.T) {
+	// left half red, right half blue.
+	src := image.NewRGBA(image.Rect(0, 0, 80, 40))
+	for x := 0; x < 80; x++ {
+		for y := 0; y < 40; y++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 40 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+
+	resized := resizeImage(src, resizeRequest{width: 40, fit: "contain"})
+	require.Equal(t, image.Pt(40, 20), resized.Bounds().Size())
+
+	cropped := resizeImage(src, resizeRequest{width: 20, height: 20, fit: "cover"})
+	require.Equal(t, image.Pt(20, 20), cropped.Bounds().Size())
+	r, _, b, _ := cropped.At(2, 10).RGBA()
+	assert.True(t, r > b, "left of the center crop should be red")
+	r, _, b, _ = cropped.At(17, 10).RGBA()
+	assert.True(t, b > r, "right of the center crop should be blue")
+}
+
+// countingReader counts the bytes read from it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+func TestReadImage(t *testing.T) {
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, 80, 40))))
+
+	source, format, err := readImage(bytes.NewReader(encoded.Bytes()), memory.MiB.Int64(), 80*40)
+	require.NoError(t, err)
+	assert.Equal(t, "png", format)
+	assert.Equal(t, encoded.Bytes(), source)
+
+	_, _, err = readImage(bytes.NewReader([]byte("not an image")), memory.MiB.Int64(), 0)
+	assert.Equal(t, http.StatusBadRequest, GetStatus(err, 0))
+
+	// images with too many pixels are rejected after reading their header.
+	large := &countingReader{r: io.MultiReader(bytes.NewReader(encoded.Bytes()), bytes.NewReader(make([]byte, 10*memory.MiB.Int64())))}
+	_, _, err = readImage(large, 20*memory.MiB.Int64(), 80*40-1)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, GetStatus(err, 0))
+	assert.True(t, large.n <= maxImageHeaderSize, "read %d bytes", large.n)
+}
+
+func TestResizable(t *testing.T) {
+	handler := &Handler{images: ImageConfig{MaxSourceSize: memory.MiB}}
+	small := uplink.SystemMetadata{ContentLength: 1000}
+
+	assert.True(t, handler.resizable(&uplink.Object{Key: "a.jpg", System: small}))
+	assert.True(t, handler.resizable(&uplink.Object{Key: "a.png", System: small}))
+	assert.False(t, handler.resizable(&uplink.Object{Key: "a.svg", System: small}))
+	assert.False(t, handler.resizable(&uplink.Object{Key: "a.txt", System: small}))
+	assert.False(t, handler.resizable(&uplink.Object{Key: "a.jpg", System: uplink.SystemMetadata{ContentLength: 2 * memory.MiB.Int64()}}))
+	assert.False(t, (&Handler{}).resizable(&uplink.Object{Key: "a.jpg", System: small}))
+}
+
+func TestETagMatches(t *testing.T) {
+	assert.True(t, etagMatches(`"abc"`, `"abc"`))
+	assert.True(t, etagMatches(`"x", W/"abc"`, `"abc"`))
+	assert.True(t, etagMatches(`*`, `"abc"`))
+	assert.False(t, etagMatches(``, `"abc"`))
+	assert.False(t, etagMatches(`"abd"`, `"abc"`))
+}
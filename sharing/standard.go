@@ -31,6 +31,24 @@ func (handler *Handler) handleStandard(ctx context.Context, w http.ResponseWrite
 		return nil
 	}
 
+	// QR codes only encode the link, so the access doesn't need to be
+	// resolved for them.
+	if queryFlagLookup(r.URL.Query(), "qr", false) {
+		return handler.serveQR(ctx, w, r)
+	}
+
+	if err := handler.parseStandardPath(ctx, r, path, &pr); err != nil {
+		return err
+	}
+
+	return handler.present(ctx, w, r, &pr)
+}
+
+// parseStandardPath fills in pr from the path of a standard request after
+// its /raw/ or /s/ prefix, resolving the access it contains.
+func (handler *Handler) parseStandardPath(ctx context.Context, r *http.Request, path string, pr *parsedRequest) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
 	var serializedAccess string
 	parts := strings.SplitN(path, "/", 3)
 	switch len(parts) {
@@ -50,7 +68,7 @@ func (handler *Handler) handleStandard(ctx context.Context, w http.ResponseWrite
 		pr.realKey = parts[2]
 	}
 
-	access, err := parseAccess(ctx, serializedAccess, handler.authConfig,
+	access, err := handler.parseAccess(ctx, serializedAccess,
 		getClientIP(handler.trustedClientIPsList, r),
 	)
 	if err != nil {
@@ -62,6 +80,6 @@ func (handler *Handler) handleStandard(ctx context.Context, w http.ResponseWrite
 	pr.visibleKey = pr.realKey
 	pr.title = pr.bucket
 	pr.root = breadcrumb{Prefix: pr.bucket, URL: "/s/" + serializedAccess + "/" + pr.bucket + "/"}
-
-	return handler.present(ctx, w, r, &pr)
+	pr.rawRoot = "/raw/" + serializedAccess + "/" + pr.bucket + "/"
+	return nil
 }
@@ -17,7 +17,7 @@ import (
 type txtRecords struct {
 	maxTTL time.Duration
 	dns    *DNSClient
-	auth   AuthServiceConfig
+	auth   *AuthServiceClient
 
 	cache       sync.Map
 	updateLocks MutexGroup
@@ -36,7 +36,7 @@ type txtRecord struct {
 	expiration time.Time
 }
 
-func newTxtRecords(maxTTL time.Duration, dns *DNSClient, auth AuthServiceConfig) *txtRecords {
+func newTxtRecords(maxTTL time.Duration, dns *DNSClient, auth *AuthServiceClient) *txtRecords {
 	return &txtRecords{
 		maxTTL: maxTTL,
 		dns:    dns,
@@ -215,6 +215,14 @@ func testHandlerRequests(t *testing.T, ctx *testcontext.Context, planet *testpla
 			status: http.StatusOK,
 			body:   "foo",
 		},
+		{
+			name:   "GET prefix listing json",
+			method: "GET",
+			path:   path.Join("s", serializedAccess, "testbucket", "test") + "/?format=json",
+			status: http.StatusOK,
+			header: http.Header{"Content-Type": {"application/json"}},
+			body:   `"key":"foo"`,
+		},
 		{
 			name:   "GET prefix listing empty",
 			method: "GET",